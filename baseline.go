@@ -0,0 +1,107 @@
+package datadash
+
+import (
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BaselineRecord is one record of a Baseline. Values holds one value per
+// column, NaN for missing fields. Time is zero when the label isn't a
+// timestamp.
+type BaselineRecord struct {
+	Label  string
+	Time   time.Time
+	Values []float64
+}
+
+// Baseline holds the records of a previous run, replayed next to the current
+// records for comparison either by record offset or by the time elapsed since
+// the first record.
+type Baseline struct {
+	Columns []string
+	Records []BaselineRecord
+	timed   bool
+}
+
+// LoadBaseline reads all records of r.
+func LoadBaseline(r ColumnReader) (*Baseline, error) {
+	b := &Baseline{timed: true}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) == 0 {
+			continue
+		}
+		rec := BaselineRecord{Label: record[0]}
+		for _, v := range record[1:] {
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				f = math.NaN()
+			}
+			rec.Values = append(rec.Values, f)
+		}
+		var ok bool
+		if rec.Time, ok = ParseTimestamp(rec.Label); !ok {
+			b.timed = false
+		}
+		b.Records = append(b.Records, rec)
+	}
+	b.Columns = r.Columns()
+	//records read before columns were added are shorter
+	for i := range b.Records {
+		for len(b.Records[i].Values) < len(b.Columns) {
+			b.Records[i].Values = append(b.Records[i].Values, math.NaN())
+		}
+	}
+	return b, nil
+}
+
+// Timed reports whether the labels of all records are timestamps, which is
+// required by At.
+func (b *Baseline) Timed() bool {
+	return b.timed && len(b.Records) > 0
+}
+
+// Column returns the index of the named column in the values of a record, or
+// -1 when the baseline has no such column.
+func (b *Baseline) Column(name string) int {
+	for i, c := range b.Columns {
+		if strings.TrimSpace(c) == strings.TrimSpace(name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Record returns the record at offset i.
+func (b *Baseline) Record(i int) (BaselineRecord, bool) {
+	if i < 0 || i >= len(b.Records) {
+		return BaselineRecord{}, false
+	}
+	return b.Records[i], true
+}
+
+// At returns the last record at most elapsed after the first one, it returns
+// false once elapsed is past the last record.
+func (b *Baseline) At(elapsed time.Duration) (BaselineRecord, bool) {
+	if !b.Timed() {
+		return BaselineRecord{}, false
+	}
+	start := b.Records[0].Time
+	if elapsed < 0 || elapsed > b.Records[len(b.Records)-1].Time.Sub(start) {
+		return BaselineRecord{}, false
+	}
+	i := sort.Search(len(b.Records), func(i int) bool {
+		return b.Records[i].Time.Sub(start) > elapsed
+	})
+	return b.Records[i-1], true
+}
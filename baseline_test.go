@@ -0,0 +1,75 @@
+package datadash
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func loadBaseline(t *testing.T, data string) *Baseline {
+	t.Helper()
+	r, err := NewCSVReader(strings.NewReader(data), '\t')
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := LoadBaseline(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestBaselineRecord(t *testing.T) {
+	b := loadBaseline(t, "x\tlatency\terrors\na\t10\t1\nb\tn/a\nc\t30\t3\n")
+	if b.Timed() {
+		t.Error("Timed() = true for labels which aren't timestamps")
+	}
+	if _, ok := b.At(0); ok {
+		t.Error("At(0) succeeded without timestamps")
+	}
+	if b.Column("errors") != 1 || b.Column(" latency ") != 0 || b.Column("load") != -1 {
+		t.Errorf("Column() = %d %d %d, want 1 0 -1", b.Column("errors"), b.Column(" latency "), b.Column("load"))
+	}
+	//records are aligned by offset, missing and unparsable cells are NaN
+	for i, want := range [][]float64{{10, 1}, {math.NaN(), math.NaN()}, {30, 3}} {
+		rec, ok := b.Record(i)
+		if !ok || !sameValues(rec.Values, want) {
+			t.Errorf("Record(%d) = %v %t, want %v", i, rec.Values, ok, want)
+		}
+	}
+	for _, i := range []int{-1, 3} {
+		if rec, ok := b.Record(i); ok {
+			t.Errorf("Record(%d) = %v, want none", i, rec)
+		}
+	}
+}
+
+func TestBaselineAt(t *testing.T) {
+	b := loadBaseline(t, "x\tlatency\n09:00:00\t10\n09:00:01\t20\n09:00:03\t30\n09:00:03.5\t40\n")
+	if !b.Timed() {
+		t.Fatal("Timed() = false for timestamps")
+	}
+	//the last record at most elapsed after the first one
+	for _, tc := range []struct {
+		elapsed time.Duration
+		label   string
+	}{
+		{0, "09:00:00"},
+		{999 * time.Millisecond, "09:00:00"},
+		{time.Second, "09:00:01"},
+		{2 * time.Second, "09:00:01"},
+		{3 * time.Second, "09:00:03"},
+		{3500 * time.Millisecond, "09:00:03.5"},
+	} {
+		rec, ok := b.At(tc.elapsed)
+		if !ok || rec.Label != tc.label {
+			t.Errorf("At(%s) = %q %t, want %q", tc.elapsed, rec.Label, ok, tc.label)
+		}
+	}
+	for _, elapsed := range []time.Duration{-time.Millisecond, 3501 * time.Millisecond} {
+		if rec, ok := b.At(elapsed); ok {
+			t.Errorf("At(%s) = %q, want none", elapsed, rec.Label)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package datadash
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/montanaflynn/stats"
+)
+
+// Aggregate reduces the values collected in a bucket to a single value.
+type Aggregate func(values []float64) float64
+
+// ParseAggregate returns the aggregate named sum, mean, min, max, count or
+// pNN (a percentile, e.g. p99).
+func ParseAggregate(name string) (Aggregate, error) {
+	switch name {
+	case "sum":
+		return func(v []float64) float64 {
+			s, _ := stats.Sum(v)
+			return s
+		}, nil
+	case "mean":
+		return func(v []float64) float64 {
+			m, _ := stats.Mean(v)
+			return m
+		}, nil
+	case "min":
+		return func(v []float64) float64 {
+			m, _ := stats.Min(v)
+			return m
+		}, nil
+	case "max":
+		return func(v []float64) float64 {
+			m, _ := stats.Max(v)
+			return m
+		}, nil
+	case "count":
+		return func(v []float64) float64 {
+			return float64(len(v))
+		}, nil
+	}
+	if strings.HasPrefix(name, "p") {
+		if p, err := strconv.ParseFloat(name[1:], 64); err == nil && p > 0 && p <= 100 {
+			return func(v []float64) float64 {
+				q, _ := stats.PercentileNearestRank(v, p)
+				return q
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown aggregate %q, must be one of: sum, mean, min, max, count, pNN", name)
+}
+
+// Bucket groups records into fixed width time buckets and reduces every
+// column of a bucket to one value. NaN values mark missing fields and are
+// left out of the aggregate.
+type Bucket struct {
+	Width     time.Duration
+	Aggregate Aggregate
+	start     time.Time
+	values    [][]float64
+	open      bool
+}
+
+// NewBucket returns a Bucket of the given width.
+func NewBucket(width time.Duration, aggregate Aggregate) *Bucket {
+	return &Bucket{Width: width, Aggregate: aggregate}
+}
+
+// Add adds the values of a record observed at ts. If ts falls outside of the
+// open bucket, that bucket is closed and its label and aggregated values are
+// returned with ok set.
+func (b *Bucket) Add(ts time.Time, values []float64) (label string, out []float64, ok bool) {
+	start := ts.Truncate(b.Width)
+	if b.open && !start.Equal(b.start) {
+		label, out, ok = b.Flush()
+	}
+	if !b.open {
+		b.start, b.open = start, true
+	}
+	for len(b.values) < len(values) {
+		b.values = append(b.values, nil)
+	}
+	for i, v := range values {
+		if !math.IsNaN(v) {
+			b.values[i] = append(b.values[i], v)
+		}
+	}
+	return label, out, ok
+}
+
+// Expired reports whether the open bucket ended before now.
+func (b *Bucket) Expired(now time.Time) bool {
+	return b.open && !now.Before(b.start.Add(b.Width))
+}
+
+// Flush closes the open bucket and returns its label and aggregated values.
+// Columns without values in the bucket are returned as NaN.
+func (b *Bucket) Flush() (label string, out []float64, ok bool) {
+	if !b.open {
+		return "", nil, false
+	}
+	out = make([]float64, len(b.values))
+	for i, v := range b.values {
+		if len(v) == 0 {
+			out[i] = math.NaN()
+		} else {
+			out[i] = b.Aggregate(v)
+		}
+		b.values[i] = v[:0]
+	}
+	b.open = false
+	return b.label(), out, true
+}
+
+func (b *Bucket) label() string {
+	if b.Width < time.Second {
+		return b.start.Format("15:04:05.000")
+	}
+	return b.start.Format("15:04:05")
+}
@@ -0,0 +1,112 @@
+package datadash
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseAggregate(t *testing.T) {
+	values := []float64{4, 1, 3, 2, 10}
+	for _, tc := range []struct {
+		name string
+		want float64
+	}{
+		{"sum", 20},
+		{"mean", 4},
+		{"min", 1},
+		{"max", 10},
+		{"count", 5},
+		{"p50", 3},
+		{"p80", 4},
+		{"p100", 10},
+		{"p99.9", 10},
+	} {
+		agg, err := ParseAggregate(tc.name)
+		if err != nil {
+			t.Errorf("ParseAggregate(%q): %v", tc.name, err)
+			continue
+		}
+		if got := agg(values); got != tc.want {
+			t.Errorf("%s of %v = %v, want %v", tc.name, values, got, tc.want)
+		}
+	}
+	for _, name := range []string{"p0", "p101", "p-1", "p", "pxx", "foo", "", "Mean"} {
+		if _, err := ParseAggregate(name); err == nil {
+			t.Errorf("ParseAggregate(%q) succeeded", name)
+		}
+	}
+}
+
+func TestBucket(t *testing.T) {
+	sum, _ := ParseAggregate("sum")
+	start := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+	nan := math.NaN()
+	b := NewBucket(time.Second, sum)
+	for _, step := range []struct {
+		ts     time.Duration
+		values []float64
+		label  string
+		want   []float64
+	}{
+		{0, []float64{1, 2}, "", nil},
+		{500 * time.Millisecond, []float64{3, nan}, "", nil},
+		//the first record of the next bucket closes the previous one
+		{1200 * time.Millisecond, []float64{5, 6}, "10:00:00", []float64{4, 2}},
+		//empty buckets are skipped, a longer record grows the columns
+		{3 * time.Second, []float64{nan, 1, 7}, "10:00:01", []float64{5, 6}},
+	} {
+		label, out, ok := b.Add(at(step.ts), step.values)
+		if ok != (step.want != nil) || label != step.label || !sameValues(out, step.want) {
+			t.Errorf("Add(+%s, %v) = %q, %v, %t, want %q, %v", step.ts, step.values, label, out, ok, step.label, step.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		now  time.Duration
+		want bool
+	}{
+		{3 * time.Second, false},
+		{3900 * time.Millisecond, false},
+		{4 * time.Second, true},
+		{time.Minute, true},
+	} {
+		if got := b.Expired(at(tc.now)); got != tc.want {
+			t.Errorf("Expired(+%s) = %t, want %t", tc.now, got, tc.want)
+		}
+	}
+
+	label, out, ok := b.Flush()
+	if !ok || label != "10:00:03" || !sameValues(out, []float64{nan, 1, 7}) {
+		t.Errorf("Flush() = %q, %v, %t, want the bucket of 10:00:03 with the first column empty", label, out, ok)
+	}
+	if _, _, ok := b.Flush(); ok {
+		t.Error("Flush() of a closed bucket succeeded")
+	}
+	if b.Expired(at(time.Hour)) {
+		t.Error("Expired() of a closed bucket = true")
+	}
+}
+
+func TestBucketSubsecondLabel(t *testing.T) {
+	mean, _ := ParseAggregate("mean")
+	b := NewBucket(250*time.Millisecond, mean)
+	b.Add(time.Date(2026, 1, 2, 10, 0, 0, 600e6, time.UTC), []float64{1})
+	if label, _, _ := b.Flush(); label != "10:00:00.500" {
+		t.Errorf("Flush() label = %q, want 10:00:00.500", label)
+	}
+}
+
+// sameValues compares values, NaN being equal to NaN.
+func sameValues(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !sameValue(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
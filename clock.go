@@ -0,0 +1,203 @@
+package datadash
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock tells the time and creates the tickers redrawing the widgets.
+// RealClock follows the system time, a FakeClock only moves when advanced,
+// which makes drawing deterministic in tests.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers ticks on a channel like a time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// RealClock is the Clock of the time package.
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// Periodic calls fn every interval on a new goroutine until ctx is done or fn
+// returns an error. The error is sent on the returned channel, which is
+// closed once fn is no longer called. The ticker is created before Periodic
+// returns, so advancing a FakeClock right after reaches fn.
+func Periodic(ctx context.Context, clock Clock, interval time.Duration, fn func() error) <-chan error {
+	ticker := clock.NewTicker(interval)
+	fake, _ := ticker.(*fakeTicker)
+	if fake != nil {
+		fake.acked.Store(true)
+	}
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C():
+				err := fn()
+				if fake != nil {
+					fake.ack()
+				}
+				if err != nil {
+					errc <- err
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return errc
+}
+
+// FakeClock is a Clock which moves only when advanced. Advance delivers the
+// ticks which are due and waits for the functions called by Periodic to
+// return.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+// NewFakeClock returns a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTicker returns a ticker ticking every d from now on.
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d <= 0 {
+		panic("non-positive interval for FakeClock.NewTicker")
+	}
+	t := &fakeTicker{
+		c:        make(chan time.Time),
+		acks:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+		interval: d,
+		next:     c.now.Add(d),
+	}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Advance moves the clock forward by d, delivering the ticks in time order.
+// Every tick waits for its receiver, and for the function called by Periodic
+// to return.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	c.mu.Unlock()
+	for {
+		c.mu.Lock()
+		var due *fakeTicker
+		live := c.tickers[:0]
+		for _, t := range c.tickers {
+			if t.isStopped() {
+				continue
+			}
+			live = append(live, t)
+			if !t.next.After(end) && (due == nil || t.next.Before(due.next)) {
+				due = t
+			}
+		}
+		c.tickers = live
+		if due == nil {
+			c.now = end
+			c.mu.Unlock()
+			return
+		}
+		c.now = due.next
+		due.next = due.next.Add(due.interval)
+		now := c.now
+		c.mu.Unlock()
+
+		due.deliver(now)
+	}
+}
+
+type fakeTicker struct {
+	c       chan time.Time
+	acks    chan struct{}
+	stopped chan struct{}
+	stop    sync.Once
+	// acked is set for the tickers of Periodic, which acknowledge ticks
+	acked    atomic.Bool
+	interval time.Duration
+	next     time.Time
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.stop.Do(func() { close(t.stopped) })
+}
+
+func (t *fakeTicker) isStopped() bool {
+	select {
+	case <-t.stopped:
+		return true
+	default:
+		return false
+	}
+}
+
+// deliver sends a tick and, for the tickers of Periodic, waits for it to be
+// handled.
+func (t *fakeTicker) deliver(now time.Time) {
+	select {
+	case t.c <- now:
+	case <-t.stopped:
+		return
+	}
+	if !t.acked.Load() {
+		return
+	}
+	select {
+	case <-t.acks:
+	case <-t.stopped:
+	}
+}
+
+// ack is called by Periodic once the function called for a tick returned.
+func (t *fakeTicker) ack() {
+	select {
+	case t.acks <- struct{}{}:
+	case <-t.stopped:
+	}
+}
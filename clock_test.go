@@ -0,0 +1,43 @@
+package datadash
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFakeClockPeriodic(t *testing.T) {
+	start := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var fast, slow []time.Time
+	Periodic(ctx, clock, 10*time.Millisecond, func() error {
+		fast = append(fast, clock.Now())
+		return nil
+	})
+	failed := errors.New("failed")
+	errc := Periodic(ctx, clock, 25*time.Millisecond, func() error {
+		slow = append(slow, clock.Now())
+		if len(slow) == 2 {
+			return failed
+		}
+		return nil
+	})
+
+	clock.Advance(100 * time.Millisecond)
+	if len(fast) != 10 || !fast[9].Equal(start.Add(100*time.Millisecond)) {
+		t.Errorf("fast ticks = %v, want 10 up to +100ms", fast)
+	}
+	if len(slow) != 2 || !slow[1].Equal(start.Add(50*time.Millisecond)) {
+		t.Errorf("slow ticks = %v, want 2 up to +50ms, the second failing", slow)
+	}
+	if err := <-errc; err != failed {
+		t.Errorf("Periodic error = %v, want %v", err, failed)
+	}
+	if now := clock.Now(); !now.Equal(start.Add(100 * time.Millisecond)) {
+		t.Errorf("Now() = %v after advancing 100ms", now)
+	}
+}
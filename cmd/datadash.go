@@ -1,22 +1,28 @@
 package main
 
 import (
-	"bufio"
 	"context"
-	"encoding/csv"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
+	"unicode"
 
 	termutil "github.com/andrew-d/go-termutil"
 	"github.com/mum4k/termdash"
+	"github.com/mum4k/termdash/cell"
 	"github.com/mum4k/termdash/container"
 	"github.com/mum4k/termdash/keyboard"
+	"github.com/mum4k/termdash/linestyle"
 	"github.com/mum4k/termdash/terminal/termbox"
 	"github.com/mum4k/termdash/terminal/terminalapi"
+	"github.com/mum4k/termdash/widgets/text"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
 
 	"github.com/keithknott26/datadash"
@@ -24,356 +30,921 @@ import (
 
 const (
 	BUFFER_SIZE = 1440
+	//rootID identifies the container holding the whole dashboard
+	rootID = "root"
+	//qualityLines is the number of offending records listed in the data
+	//quality panel
+	qualityLines = 4
 )
 
 var (
-	app            = kingpin.New("datadash", "A Data Visualization Tool")
-	debug          = app.Flag("debug", "Enable Debug Mode").Bool()
-	delimiter      = app.Flag("delimiter", "Record Delimiter. Default: \t").Short('d').Default("\t").String()
-	labelMode      = app.Flag("label-mode", "X-Axis Labels: 'first' (use the first record in the column) or 'time' (use the current time)").Short('m').Default("first").String()
-	scrollData     = app.Flag("scroll", "Whether or not to scroll chart data (true, false). Default: false").Short('s').Default("false").Bool()
-	avgLine        = app.Flag("average-line", "Enables the line representing the average of values. Default: false").Short('a').Default("false").Bool()
-	avgSeek        = app.Flag("average-seek", "The number of values to consider when displaying the average line: (50,100,500...) Default: 500").Short('z').Default("500").Int()
-	yAxisAdaptive  = app.Flag("adaptive-y", "Makes the Y axis adapt its base value depending on the provided series. Without this option, the Y axis always starts at the zero value regardless of values available in the series. Default: false").Short('y').Default("false").Bool()
-	graphType      = app.Flag("graph-type", "The type of graphs to display (line, bar, spark). Default: line").Short('g').Default("line").String()
-	redrawInterval = app.Flag("redraw-interval", "The interval at which objects on the screen are redrawn: (100ms,250ms,1s,5s..) Default 10ms").Short('r').Default("10ms").Duration()
-	seekInterval   = app.Flag("seek-interval", "The interval at which records (lines) are read from the datasource: (100ms,250ms,1s,5s..) Default: 20ms").Short('l').Default("20ms").Duration()
-	inputFile      = app.Arg("input file", "A file containing a label header, and data in columns separated by delimiter 'd'.\nData piped from Stdin uses the same format").File()
-
-	ctx    context.Context
-	stream *datadash.Row
-
-	row1 *datadash.Row
-	row2 *datadash.Row
-	row3 *datadash.Row
-	row4 *datadash.Row
-	row5 *datadash.Row
-
-	dataChan = make(chan []string, 10)
-	labels   = make([]string, 0, 0)
-	graphs   = 1
-	//scrolling speed control
-	slower    = false
-	faster    = false
-	interrupt = false
-	resume    = false
+	//app parses the command line into flags, parseArgs creates both again
+	//for each parse
+	app   *kingpin.Application
+	flags *commandFlags
+
+	//state is the state of the current run, set up by setup
+	state = newRunState(datadash.RealClock)
 )
 
-func layout(ctx context.Context, t terminalapi.Terminal, labels []string) (*container.Container, error) {
-	var labels0 string
-	var labels1 string
-	var labels2 string
-	var labels3 string
-	var labels4 string
-	var labels5 string
-	switch graphs {
-	case 0:
-		labels0 = "Streaming Data..."
-		labels1 = "Empty"
-		labels2 = "Empty"
-		labels3 = "Empty"
-		labels4 = "Empty"
-		labels5 = "Empty"
-		*labelMode = "time"
-	case 1:
-		labels0 = labels[0]
-		labels1 = labels[1]
-		labels2 = "Empty"
-		labels3 = "Empty"
-		labels4 = "Empty"
-		labels5 = "Empty"
-	case 2:
-		labels0 = labels[0]
-		labels1 = labels[1]
-		labels2 = labels[2]
-		labels3 = "Empty"
-		labels4 = "Empty"
-		labels5 = "Empty"
-	case 3:
-		labels0 = labels[0]
-		labels1 = labels[1]
-		labels2 = labels[2]
-		labels3 = labels[3]
-		labels4 = "Empty"
-		labels5 = "Empty"
-	case 4:
-		labels0 = labels[0]
-		labels1 = labels[1]
-		labels2 = labels[2]
-		labels3 = labels[3]
-		labels4 = labels[4]
-		labels5 = "Empty"
-	case 5:
-		labels0 = labels[0]
-		labels1 = labels[1]
-		labels2 = labels[2]
-		labels3 = labels[3]
-		labels4 = labels[4]
-		labels5 = labels[5]
-	}
-
-	//types := make([]rune, len(labels), len(labels))
-	//copy(types, []rune(strings.ToUpper(*panelTypes)))
-	//for i, label := range labels {
-	//	var panelType int
-	//	switch types[i] {
-	//	case 'L':
-	//		panelType = termeter.LINE
-	//	case 'D':
-	//		panelType = termeter.CDF
-	//	case 'C':
-	//		panelType = termeter.COUNTER
-	//	default:
-	///		graphType = "L"
-	//		//Initialize Row
-	//		stream.InitWidgets(ctx, labels0, *redrawInterval)
-	//		stream.Context = ctx
-	//		StreamingDataRow := stream.ContainerOptions(stream.Context, graphType)
-	//		if err != nil {
-	//			panelType = termeter.COUNTER
-	//		}
-	//	}
-	//	app.AddPanel(label, panelType, options)
-	//}
-
-	//Initialize Rows (up to 5 are supported)
-	stream.InitWidgets(ctx, *graphType, labels0, *redrawInterval, *seekInterval)
-	stream.Context = ctx
-	StreamingDataRow := stream.ContainerOptions(stream.Context, *graphType)
-
-	row1.InitWidgets(ctx, *graphType, labels1, *redrawInterval, *seekInterval)
-	row1.Context = ctx
-	FirstRow := row1.ContainerOptions(row1.Context, *graphType)
-
-	row2.InitWidgets(ctx, *graphType, labels2, *redrawInterval, *seekInterval)
-	row2.Context = ctx
-	SecondRow := row2.ContainerOptions(row2.Context, *graphType)
-
-	row3.InitWidgets(ctx, *graphType, labels3, *redrawInterval, *seekInterval)
-	row3.Context = ctx
-	ThirdRow := row3.ContainerOptions(row3.Context, *graphType)
-
-	row4.InitWidgets(ctx, *graphType, labels4, *redrawInterval, *seekInterval)
-	row4.Context = ctx
-	FourthRow := row4.ContainerOptions(row4.Context, *graphType)
-
-	row5.InitWidgets(ctx, *graphType, labels5, *redrawInterval, *seekInterval)
-	row5.Context = ctx
-	FifthRow := row5.ContainerOptions(row5.Context, *graphType)
-
-	TopHalf := []container.Option{
-		container.SplitHorizontal(
-			container.Top(FirstRow...),
-			container.Bottom(SecondRow...),
-			container.SplitPercent(50),
-		),
+// commandFlags are the values of the command line flags.
+type commandFlags struct {
+	debug          bool
+	delimiter      string
+	whitespace     bool
+	headerMode     string
+	labelMode      string
+	scrollData     bool
+	avgLine        bool
+	avgSeek        int
+	yAxisAdaptive  bool
+	graphType      string
+	redrawInterval time.Duration
+	seekInterval   time.Duration
+	themeName      string
+	colorDepth     string
+	deriveExprs    []string
+	transforms     []string
+	bucketWidth    time.Duration
+	aggregate      string
+	format         string
+	labelKey       string
+	keys           string
+	regexExpr      string
+	scrapeURL      string
+	metrics        []string
+	listenAddr     string
+	sourceTag      bool
+	statsdAddr     string
+	execCommand    string
+	pollURL        string
+	pollFields     []string
+	execTimeout    time.Duration
+	every          time.Duration
+	mergeMode      string
+	overlay        bool
+	baselineFile   string
+	baselineAlign  string
+	sinkSpecs      []string
+	inputFiles     []string
+}
+
+// newApp returns the command line application parsing the flags into f.
+func newApp(f *commandFlags) *kingpin.Application {
+	app := kingpin.New("datadash", "A Data Visualization Tool")
+	app.Flag("debug", "Enable Debug Mode").BoolVar(&f.debug)
+	app.Flag("delimiter", "Record Delimiter. Default: detected from the first lines (tab, comma, semicolon, pipe or runs of whitespace)").Short('d').StringVar(&f.delimiter)
+	app.Flag("whitespace", "Splits records on runs of spaces and tabs, for the aligned columns printed by vmstat, iostat, ps or sar. Banner and header lines they repeat are skipped, a first column of plain numbers is plotted with the current time as X-Axis label. Default: detected along with the delimiter").Short('w').BoolVar(&f.whitespace)
+	app.Flag("header", "Whether delimited data starts with a header line naming the columns (auto, yes, no). Without one the columns are named col1..colN. 'auto' detects a header from the first lines. Default: auto").Default("auto").EnumVar(&f.headerMode, "auto", "yes", "no")
+	app.Flag("label-mode", "X-Axis Labels: 'first' (use the first record in the column) or 'time' (use the current time)").Short('m').Default("first").StringVar(&f.labelMode)
+	app.Flag("scroll", "Whether or not to scroll chart data (true, false). Default: false").Short('s').Default("false").BoolVar(&f.scrollData)
+	app.Flag("average-line", "Enables the line representing the average of values. Default: false").Short('a').Default("false").BoolVar(&f.avgLine)
+	app.Flag("average-seek", "The number of values to consider when displaying the average line: (50,100,500...) Default: 500").Short('z').Default("500").IntVar(&f.avgSeek)
+	app.Flag("adaptive-y", "Makes the Y axis adapt its base value depending on the provided series. Without this option, the Y axis always starts at the zero value regardless of values available in the series. Default: false").Short('y').Default("false").BoolVar(&f.yAxisAdaptive)
+	app.Flag("graph-type", "The type of graphs to display (line, bar, spark). Default: line").Short('g').Default("line").StringVar(&f.graphType)
+	app.Flag("redraw-interval", "The interval at which objects on the screen are redrawn: (100ms,250ms,1s,5s..) Default 10ms").Short('r').Default("10ms").DurationVar(&f.redrawInterval)
+	app.Flag("seek-interval", "The interval at which records (lines) are read from the datasource: (100ms,250ms,1s,5s..) Default: 20ms").Short('l').Default("20ms").DurationVar(&f.seekInterval)
+	app.Flag("theme", "The color theme ("+strings.Join(datadash.ThemeNames(), ", ")+"), may also be set with $DATADASH_THEME. Default: dark").Short('t').Envar("DATADASH_THEME").Default(datadash.DefaultTheme).EnumVar(&f.themeName, datadash.ThemeNames()...)
+	app.Flag("colors", "The number of colors supported by the terminal (auto, 16, 256, truecolor, none). 'auto' detects it from $TERM and $COLORTERM. Default: auto").Default("auto").EnumVar(&f.colorDepth, "auto", "16", "256", "truecolor", "none")
+	app.Flag("derive", "Adds a column computed from the others, e.g. 'rate=col3/col2*100'. colN is the Nth field of the record, header labels may be used by name. Can be repeated.").PlaceHolder("NAME=EXPR").StringsVar(&f.deriveExprs)
+	app.Flag("transform", "Converts a cumulative counter column into the difference between records ('delta') or a per second rate ('rate'), e.g. 'col3=rate'. Rates use the X-Axis label as timestamp when it is one, the arrival time otherwise. Can be repeated.").PlaceHolder("COLUMN=MODE").StringsVar(&f.transforms)
+	app.Flag("bucket", "Groups records into buckets of this duration (1s, 1m..), by the X-Axis label when it is a timestamp or by arrival time otherwise, and plots one aggregated point per bucket. Default: off").DurationVar(&f.bucketWidth)
+	app.Flag("aggregate", "How the values of a bucket are combined: sum, mean, min, max, count or a percentile like p99. Default: mean").Default("mean").StringVar(&f.aggregate)
+	app.Flag("format", "The input format ("+strings.Join(datadash.FormatNames(), ", ")+"): 'csv' (delimited columns), 'logfmt' (key=value pairs) or 'influx' (InfluxDB line protocol). Default: csv").Short('f').Default("csv").EnumVar(&f.format, datadash.FormatNames()...)
+	app.Flag("label-key", "The logfmt key used as X-Axis label. Without it the current time is used.").PlaceHolder("KEY").StringVar(&f.labelKey)
+	app.Flag("keys", "Comma separated logfmt keys to plot. Default: the numeric keys of the first line").PlaceHolder("KEY,..").StringVar(&f.keys)
+	app.Flag("regex", "Reads columns from the named capture groups of a regular expression instead of delimited data, e.g. 'latency=(?P<latency>\\d+)ms'. A group named 'x' is used as X-Axis label. Lines which don't match are counted and skipped.").PlaceHolder("REGEX").StringVar(&f.regexExpr)
+	app.Flag("scrape", "Polls a Prometheus /metrics endpoint instead of reading a file or Stdin. Counters are plotted as per second rates.").PlaceHolder("URL").StringVar(&f.scrapeURL)
+	app.Flag("metric", "A metric selector for --scrape, e.g. 'http_requests_total{code=~\"5..\"}'. Can be repeated.").PlaceHolder("SELECTOR").StringsVar(&f.metrics)
+	app.Flag("listen", "Accepts records from any number of concurrent clients on a socket (tcp://:9000, unix:///tmp/datadash.sock) instead of reading a file or Stdin. Each client sends records in the configured format, starting with a header for delimited data.").PlaceHolder("URL").StringVar(&f.listenAddr)
+	app.Flag("source-tag", "Prefixes the columns sent to --listen with the client's address, keeping the columns of each connection apart.").BoolVar(&f.sourceTag)
+	app.Flag("listen-statsd", "Receives StatsD counters, gauges, timers and sets over UDP on this address (e.g. :8125) and plots them aggregated every --every interval.").PlaceHolder("ADDR").StringVar(&f.statsdAddr)
+	app.Flag("exec", "Runs a shell command every --every interval and plots the records it prints, read in the configured format (delimited data starts with a header line).").PlaceHolder("COMMAND").StringVar(&f.execCommand)
+	app.Flag("poll", "Requests a JSON document every --every interval and plots the values selected with --field, along with the request latency and errors.").PlaceHolder("URL").StringVar(&f.pollURL)
+	app.Flag("field", "A path selecting a value of the --poll document, e.g. 'queue.depth' or 'workers[0].busy'. Can be repeated.").PlaceHolder("PATH").StringsVar(&f.pollFields)
+	app.Flag("timeout", "Kills an --exec command running longer than this. Default: the --every interval").DurationVar(&f.execTimeout)
+	app.Flag("every", "The interval at which --exec runs, --poll and --scrape request and --listen-statsd aggregates. Default: 5s").Default("5s").DurationVar(&f.every)
+	app.Flag("merge", "How records of several input files are combined: 'interleave' (one record of each file in turn) or 'time' (in order of their X-Axis label timestamps, records of the same time are plotted together). Default: interleave").Default("interleave").EnumVar(&f.mergeMode, "interleave", "time")
+	app.Flag("overlay", "Draws the columns of several input files sharing a name in one graph instead of one graph per file and column.").BoolVar(&f.overlay)
+	app.Flag("baseline", "Draws the records of a previous run, read from this file in the configured format, as a dimmed series in the graph of each column of the same name. The statistics show the change of the mean and p99 against it.").PlaceHolder("FILE").StringVar(&f.baselineFile)
+	app.Flag("baseline-align", "How baseline records are aligned with the current ones: 'offset' (record by record) or 'time' (by the time elapsed since the first record, the X-Axis labels of the baseline must be timestamps). Default: offset").Default("offset").EnumVar(&f.baselineAlign, "offset", "time")
+	app.Flag("sink", "Writes every processed record (after derivation, transforms and bucketing) to a tab separated file (csv:FILE), a JSON lines file (jsonl:FILE) or posts it as JSON to an http:// URL. Can be repeated.").PlaceHolder("SINK").StringsVar(&f.sinkSpecs)
+	app.Arg("input files", "Files containing a label header, and data in columns separated by delimiter 'd'. Several files or glob patterns (logs/*.tsv) are merged into one dashboard, their columns prefixed with the file name.\nData piped from Stdin uses the same format").StringsVar(&f.inputFiles)
+	return app
+}
+
+// runState is the state of a dashboard run, set up from the command line
+// and the input by setup and updated as records are plotted.
+type runState struct {
+	theme *datadash.Theme
+	//depth is the number of colors of the terminal
+	depth datadash.ColorDepth
+	rows  []*datadash.Row
+	//rowNames are the column names of the rows, passed to the sinks
+	rowNames    []string
+	sinks       *datadash.Tee
+	derivations []*datadash.Derivation
+	statusParts []func() string
+	//columnNames returns the current columns of readers adding them on the fly
+	columnNames func() []string
+	dashboard   *container.Container
+	columnIndex map[string]int
+	counters    []*datadash.Counter
+	bucket      *datadash.Bucket
+	arrivalTime bool
+	//mergeNames are the column prefixes of merged input files
+	mergeNames []string
+	//overlayGroups maps a column name to the row showing it for all files
+	overlayGroups map[string]*datadash.Row
+	overlaid      map[*datadash.Row]bool
+	//baseline is replayed next to the records, each row comparing against a
+	//column of it
+	baseline         *datadash.Baseline
+	baselineColumns  map[*datadash.Row]int
+	baselineCounters map[*datadash.Row]*datadash.Counter
+	baselineOffset   int
+	baselineStart    time.Time
+	//closers release the inputs when the run ends
+	closers []io.Closer
+
+	//clock times the records and redraws, a FakeClock in tests
+	clock datadash.Clock
+	//qualities check the records of every input, one per merged file, their
+	//problems are shown in the data quality panel laid out once the first one
+	//occurs
+	qualities     []*datadash.Quality
+	statusBar     *text.Text
+	qualityPanel  *text.Text
+	qualityShown  bool
+	fatalErrors   chan error
+	stopDashboard context.CancelFunc
+
+	//dataChan carries the records read, it is closed at the end of the input
+	dataChan chan []string
+	graphs   int
+}
+
+// newRunState returns the state of a run timed by clock.
+func newRunState(clock datadash.Clock) *runState {
+	return &runState{
+		columnIndex:      map[string]int{},
+		overlayGroups:    map[string]*datadash.Row{},
+		overlaid:         map[*datadash.Row]bool{},
+		baselineColumns:  map[*datadash.Row]int{},
+		baselineCounters: map[*datadash.Row]*datadash.Counter{},
+		clock:            clock,
+		fatalErrors:      make(chan error, 1),
+		dataChan:         make(chan []string, 10),
+		graphs:           1,
 	}
-	BottomHalf := []container.Option{
-		container.SplitHorizontal(
-			container.Top(ThirdRow...),
-			container.Bottom(FourthRow...),
-			container.SplitPercent(50),
-		),
+}
+
+// recordReader reads one record (a label followed by values) per call.
+type recordReader interface {
+	Read() ([]string, error)
+}
+
+// newColumnReader returns a reader for the configured format, used for the
+// input files and Stdin and for each connection accepted by --listen.
+func newColumnReader(r io.Reader) (datadash.ColumnReader, error) {
+	if flags.regexExpr != "" {
+		return datadash.NewRegexReader(r, flags.regexExpr)
 	}
-	AllRows := []container.Option{
-		container.SplitHorizontal(
-			container.Top(TopHalf...),
-			container.Bottom(BottomHalf...),
-			container.SplitPercent(50),
-		),
+	header, _ := datadash.ParseHeaderMode(flags.headerMode)
+	opts := datadash.FormatOptions{
+		Whitespace: flags.whitespace,
+		Header:     header,
+		LabelKey:   flags.labelKey,
+	}
+	if flags.delimiter != "" {
+		opts.Delimiter = []rune(flags.delimiter)[0]
+	}
+	if flags.keys != "" {
+		opts.Keys = strings.Split(flags.keys, ",")
 	}
+	return datadash.NewFormatReader(flags.format, r, opts)
+}
 
-	switch graphs {
-	case 0:
-		c, err := container.New(t, StreamingDataRow...)
-		if err != nil {
-			return nil, err
+// openSource opens the named source reading the configured format.
+func openSource(name, location string) (datadash.Source, []string, error) {
+	src, err := datadash.NewSource(name, datadash.SourceOptions{Location: location, NewReader: newColumnReader})
+	if err != nil {
+		return nil, nil, err
+	}
+	header, err := src.Open()
+	if err != nil {
+		src.Close()
+		return nil, nil, err
+	}
+	return src, header, nil
+}
+
+// sourceStatus returns the status of the reader of src shown in the status
+// bar, nil when it has none.
+func sourceStatus(src datadash.Source) func() string {
+	stream, ok := src.(*datadash.StreamSource)
+	if !ok {
+		return nil
+	}
+	switch r := stream.Reader().(type) {
+	case *datadash.RegexReader:
+		return func() string {
+			return fmt.Sprintf("Unmatched lines: %d", r.Unmatched())
 		}
-		return c, nil
-	case 1:
-		c, err := container.New(t, FirstRow...)
-		if err != nil {
-			return nil, err
+	case *datadash.InfluxReader:
+		return func() string {
+			return fmt.Sprintf("Series: %d | Skipped lines: %d", len(r.Columns()), r.Skipped())
 		}
-		return c, nil
-	case 2:
-		c, err := container.New(
-			t,
-			container.SplitHorizontal(
-				container.Top(FirstRow...),
-				container.Bottom(SecondRow...),
-				container.SplitPercent(50),
-			),
-		)
-		if err != nil {
-			return nil, err
+	case *datadash.LogfmtReader:
+		return func() string {
+			return fmt.Sprintf("Skipped lines: %d", r.Skipped())
 		}
-		return c, nil
-	case 3:
-		c, err := container.New(
-			t,
-			container.SplitHorizontal(
-				container.Top(TopHalf...),
-				container.Bottom(ThirdRow...),
-				container.SplitPercent(66),
-			),
-		)
-		if err != nil {
+	}
+	return nil
+}
+
+func layout(ctx context.Context, t terminalapi.Terminal) (*container.Container, error) {
+	if state.graphs == 0 {
+		flags.labelMode = "time"
+	}
+	//Initialize one panel per row, stacked vertically
+	for _, r := range visibleRows() {
+		if err := r.InitWidgets(ctx, flags.graphType, r.Label, flags.redrawInterval, flags.seekInterval); err != nil {
 			return nil, err
 		}
-		return c, nil
-	case 4:
-		c, err := container.New(
-			t,
-			AllRows...,
-		)
-		if err != nil {
+		r.Context = ctx
+	}
+	var err error
+	if len(state.statusParts) > 0 {
+		if state.statusBar, err = newStatusBar(ctx); err != nil {
 			return nil, err
 		}
-		return c, nil
-	case 5:
-		c, err := container.New(
-			t,
-			container.SplitHorizontal(
-				container.Top(AllRows...),
-				container.Bottom(FifthRow...),
-				container.SplitPercent(80),
-			),
-		)
+	}
+	if state.qualityPanel, err = newQualityPanel(ctx); err != nil {
+		return nil, err
+	}
+	state.qualityShown = qualityStatus().Problems() > 0
+	return container.New(t, rootOptions()...)
+}
+
+// rootOptions returns the layout of the dashboard: the status bar, the data
+// quality panel once problems were found, and the rows.
+func rootOptions() []container.Option {
+	panels := make([][]container.Option, 0, len(state.rows))
+	for _, r := range visibleRows() {
+		panels = append(panels, r.ContainerOptions(r.Context, flags.graphType))
+	}
+	opts := datadash.Stack(panels...)
+	if state.qualityShown {
+		panel := []container.Option{
+			container.Border(linestyle.Round),
+			container.BorderColor(cell.ColorNumber(state.theme.Border)),
+			container.BorderTitle("Data Quality"),
+			container.PlaceWidget(state.qualityPanel),
+		}
+		opts = splitTop(panel, opts, qualityLines+3)
+	}
+	if state.statusBar != nil {
+		opts = splitTop([]container.Option{container.PlaceWidget(state.statusBar)}, opts, 1)
+	}
+	return append([]container.Option{container.ID(rootID)}, opts...)
+}
+
+// splitTop returns the options of a container split into top, of a fixed
+// height, and bottom.
+func splitTop(top, bottom []container.Option, height int) []container.Option {
+	return []container.Option{
+		container.SplitHorizontal(
+			container.Top(top...),
+			container.Bottom(bottom...),
+			container.SplitFixed(height),
+			//updating a container keeps its split options, the percentage
+			//set by Stack is reset as both can't be set
+			container.SplitPercent(container.DefaultSplitPercent),
+		),
+	}
+}
+
+// showQuality lays out the data quality panel once the first problem was
+// found.
+func showQuality() error {
+	if state.qualityShown || qualityStatus().Problems() == 0 {
+		return nil
+	}
+	state.qualityShown = true
+	return state.dashboard.Update(rootID, rootOptions()...)
+}
+
+// addColumns adds a row for every column which appeared in the input after
+// the dashboard was laid out, placed before the derived columns.
+func addColumns(ctx context.Context, names []string) error {
+	columns := len(state.rows) - len(state.derivations)
+	if len(names) <= columns {
+		return nil
+	}
+	added := make([]*datadash.Row, 0, len(names)-columns)
+	for i := columns; i < len(names); i++ {
+		r := newRow(names[i], i+1)
+		attachBaseline(r, names[i])
+		groupOverlay(r)
+		if !state.overlaid[r] {
+			if err := r.InitWidgets(ctx, flags.graphType, r.Label, flags.redrawInterval, flags.seekInterval); err != nil {
+				return err
+			}
+			r.Context = ctx
+		}
+		added = append(added, r)
+	}
+	state.rows = append(state.rows[:columns], append(added, state.rows[columns:]...)...)
+	state.rowNames = append(state.rowNames[:columns], append(append([]string(nil), names[columns:]...), state.rowNames[columns:]...)...)
+	state.counters = append(state.counters[:columns], append(make([]*datadash.Counter, len(added)), state.counters[columns:]...)...)
+	state.graphs = len(names)
+	return state.dashboard.Update(rootID, rootOptions()...)
+}
+
+// newStatusBar returns a one line text widget periodically showing the
+// status of the input.
+func newStatusBar(ctx context.Context) (*text.Text, error) {
+	t, err := text.New()
+	if err != nil {
+		return nil, err
+	}
+	periodic(ctx, flags.redrawInterval*10, func() error {
+		parts := make([]string, 0, len(state.statusParts))
+		for _, part := range state.statusParts {
+			parts = append(parts, part())
+		}
+		return t.Write(" "+strings.Join(parts, " | "), text.WriteReplace(), text.WriteCellOpts(cell.FgColor(cell.ColorNumber(state.theme.Pointer))))
+	})
+	return t, nil
+}
+
+// newQualityPanel returns a text widget periodically showing the data
+// quality problems and the last offending records.
+func newQualityPanel(ctx context.Context) (*text.Text, error) {
+	t, err := text.New()
+	if err != nil {
+		return nil, err
+	}
+	periodic(ctx, flags.redrawInterval*10, func() error {
+		status := qualityStatus()
+		if status.Problems() == 0 {
+			return nil
+		}
+		summary := fmt.Sprintf("Records: %d | Ragged: %d | Unparsable cells: %s | Duplicate labels: %d | Out of order: %d\n",
+			status.Records, status.Ragged, formatCounts(status.Unparsable), status.Duplicates, status.OutOfOrder)
+		t.Reset()
+		if err := t.Write(summary, text.WriteCellOpts(cell.FgColor(cell.ColorNumber(state.theme.Pointer)))); err != nil {
+			return err
+		}
+		//the text widget only writes printable characters, tabs included
+		printable := strings.Map(func(r rune) rune {
+			if r != '\n' && !unicode.IsPrint(r) {
+				return ' '
+			}
+			return r
+		}, strings.Join(status.Lines, "\n"))
+		return t.Write(printable, text.WriteCellOpts(cell.FgColor(cell.ColorNumber(state.theme.Text))))
+	})
+	return t, nil
+}
+
+// formatCounts returns counts per column like "latency 3, errors 1", sorted
+// by column, or 0 when there are none.
+func formatCounts(counts map[string]int) string {
+	if len(counts) == 0 {
+		return "0"
+	}
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s %d", name, counts[name])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// fatal stops the dashboard because of err, which run returns once the
+// terminal is restored. Only the first error is kept.
+func fatal(err error) {
+	select {
+	case state.fatalErrors <- err:
+	default:
+	}
+	if state.stopDashboard != nil {
+		state.stopDashboard()
+	}
+}
+
+// qualityStatus returns the problems found in all inputs. The problems of
+// merged files are told apart by the file name.
+func qualityStatus() datadash.QualityStatus {
+	var total datadash.QualityStatus
+	total.Unparsable = map[string]int{}
+	for i, q := range state.qualities {
+		status := q.Status()
+		prefix := ""
+		if len(state.qualities) > 1 {
+			prefix = state.mergeNames[i] + "/"
+		}
+		total.Records += status.Records
+		total.Ragged += status.Ragged
+		total.Duplicates += status.Duplicates
+		total.OutOfOrder += status.OutOfOrder
+		for column, n := range status.Unparsable {
+			total.Unparsable[prefix+column] += n
+		}
+		for _, line := range status.Lines {
+			if prefix != "" {
+				line = strings.TrimSuffix(prefix, "/") + ": " + line
+			}
+			total.Lines = append(total.Lines, line)
+		}
+	}
+	if len(total.Lines) > qualityLines {
+		total.Lines = total.Lines[len(total.Lines)-qualityLines:]
+	}
+	return total
+}
+
+// qualityChecker checks the records read from a source with quality. The
+// offending records are plotted all the same: missing fields and cells which
+// aren't numbers are skipped and extra fields ignored.
+type qualityChecker struct {
+	src     datadash.Source
+	quality *datadash.Quality
+	//csv tells the line of a record of delimited data, nil for other formats
+	csv *datadash.CSVReader
+}
+
+// checkQuality returns a reader of src checking its records with a new
+// quality for the columns of header.
+func checkQuality(src datadash.Source, header []string) qualityChecker {
+	r := qualityChecker{src: src, quality: datadash.NewQuality(header, qualityLines)}
+	state.qualities = append(state.qualities, r.quality)
+	influx := false
+	if stream, ok := src.(*datadash.StreamSource); ok {
+		r.csv, _ = stream.Reader().(*datadash.CSVReader)
+		_, influx = stream.Reader().(*datadash.InfluxReader)
+	}
+	//only delimited data has a fixed number of fields
+	r.quality.FixedFields = r.csv != nil
+	//the points of a batch of InfluxDB line protocol share their time
+	r.quality.SharedLabels = state.bucket != nil || influx
+	if r.csv != nil {
+		r.quality.Delimiter = string(r.csv.Dialect().Delimiter)
+		if r.csv.Dialect().Whitespace {
+			r.quality.Delimiter = " "
+		}
+	}
+	return r
+}
+
+// Read returns the next record.
+func (r qualityChecker) Read() ([]string, error) {
+	record, err := r.src.Read()
+	if err != nil {
+		return nil, err
+	}
+	line := 0
+	if r.csv != nil {
+		line = r.csv.Line()
+	}
+	r.quality.Check(line, record)
+	return record, nil
+}
+
+// Columns returns the columns of the source.
+func (r qualityChecker) Columns() []string {
+	return r.src.Columns()
+}
+
+// groupOverlay adds the row of a merged file's column to the row of the first
+// file having a column of the same name when --overlay is set.
+func groupOverlay(r *datadash.Row) {
+	if !flags.overlay {
+		return
+	}
+	for _, file := range state.mergeNames {
+		if !strings.HasPrefix(r.Label, file+"/") {
+			continue
+		}
+		name := strings.TrimPrefix(r.Label, file+"/")
+		primary, ok := state.overlayGroups[name]
+		if !ok {
+			state.overlayGroups[name] = r
+			r.Label = name + " [" + file + "]"
+			return
+		}
+		primary.AddOverlay(r)
+		primary.Label = strings.TrimSuffix(primary.Label, "]") + ", " + file + "]"
+		state.overlaid[r] = true
+		return
+	}
+}
+
+// attachBaseline compares the row of a column against the baseline column of
+// the same name, if any.
+func attachBaseline(r *datadash.Row, column string) {
+	if state.baseline == nil {
+		return
+	}
+	col := state.baseline.Column(column)
+	if col < 0 {
+		return
+	}
+	r.Baseline = newRow(column+" (baseline)", r.ID)
+	state.baselineColumns[r] = col
+}
+
+// loadBaseline reads the --baseline file.
+func loadBaseline(name string) (*datadash.Baseline, error) {
+	src, _, err := openSource("file", name)
+	if err != nil {
+		return nil, fmt.Errorf("baseline: %v", err)
+	}
+	defer src.Close()
+	b, err := datadash.LoadBaseline(src)
+	if err != nil {
+		return nil, fmt.Errorf("baseline: %v", err)
+	}
+	if flags.baselineAlign == "time" && !b.Timed() {
+		return nil, fmt.Errorf("baseline %s: aligning by time requires timestamps as X-Axis labels", name)
+	}
+	return b, nil
+}
+
+// plotBaseline adds the baseline record aligned with a plotted record to the
+// baseline rows. Counter transforms of a row apply to its baseline as well.
+func plotBaseline(label string) {
+	ts, ok := datadash.ParseTimestamp(label)
+	if !ok {
+		ts = state.clock.Now()
+	}
+	var rec datadash.BaselineRecord
+	if flags.baselineAlign == "time" {
+		if state.baselineStart.IsZero() {
+			state.baselineStart = ts
+		}
+		rec, ok = state.baseline.At(ts.Sub(state.baselineStart))
+	} else {
+		rec, ok = state.baseline.Record(state.baselineOffset)
+		state.baselineOffset++
+	}
+	if !ok {
+		return
+	}
+	if !rec.Time.IsZero() {
+		ts = rec.Time
+	}
+	for i, r := range state.rows {
+		col, ok := state.baselineColumns[r]
+		if !ok || math.IsNaN(rec.Values[col]) {
+			continue
+		}
+		v := rec.Values[col]
+		if i < len(state.counters) && state.counters[i] != nil {
+			if state.baselineCounters[r] == nil {
+				state.baselineCounters[r] = &datadash.Counter{Mode: state.counters[i].Mode}
+			}
+			v = state.baselineCounters[r].Next(v, ts)
+			if math.IsNaN(v) {
+				continue
+			}
+		}
+		r.Baseline.Update(v, displayLabel(label), flags.avgSeek)
+	}
+}
+
+// visibleRows returns the rows laid out on the dashboard, leaving out the
+// rows drawn as overlays of another.
+func visibleRows() []*datadash.Row {
+	visible := make([]*datadash.Row, 0, len(state.rows))
+	for _, r := range state.rows {
+		if !state.overlaid[r] {
+			visible = append(visible, r)
+		}
+	}
+	return visible
+}
+
+// expandInputs returns the input files matching the arguments, which may be
+// glob patterns.
+func expandInputs(args []string) []string {
+	var files []string
+	for _, arg := range args {
+		matches, err := filepath.Glob(arg)
+		if err != nil || len(matches) == 0 {
+			//let opening the file report the error
+			matches = []string{arg}
+		}
+		files = append(files, matches...)
+	}
+	return files
+}
+
+// mergeFiles opens the input files and returns a reader merging them, the
+// columns of each file are prefixed with its name, or its path when several
+// files have the same name.
+func mergeFiles(files []string) (*datadash.MergeReader, error) {
+	seen := map[string]int{}
+	for _, f := range files {
+		seen[filepath.Base(f)]++
+	}
+	readers := make([]datadash.ColumnReader, 0, len(files))
+	for _, f := range files {
+		name := filepath.Base(f)
+		if seen[name] > 1 {
+			name = f
+		}
+		src, header, err := openSource("file", f)
+		if err == io.EOF {
+			err = fmt.Errorf("%s: no records", f)
+		}
 		if err != nil {
 			return nil, err
 		}
-		return c, nil
-	default:
-		err := "\n\nError: Columns Detected: " + strconv.Itoa(graphs)
-		text := err + "\n\nError: This app wants a minimum of 2 columns and a maximum of 5 columns. You must include a header record:\n\n\t\tHeader record:\tIgnored<delimiter>Title\n\t\tData Row:\tX-Label<delimiter>Y-value\n\n\n\nExample:  \n\t\ttime\tADL Inserts\n\t\t00:01\t493\n\t\t00:02\t353\n\t\t00:03\t380\n\nExample:\n\t\tcol1\tcol2\n\t\t1\t493\n\t\t2\t353\n\t\t3\t321\n"
+		state.closers = append(state.closers, src)
+		readers = append(readers, checkQuality(src, header))
+		state.mergeNames = append(state.mergeNames, name)
+	}
+	return datadash.NewMergeReader(state.mergeNames, readers, flags.mergeMode == "time"), nil
+}
+
+func newRow(label string, id int) *datadash.Row {
+	return datadash.NewRow(label,
+		datadash.SeriesID(id),
+		datadash.BufferSize(BUFFER_SIZE),
+		datadash.Scroll(flags.scrollData),
+		datadash.AverageLine(flags.avgLine, flags.avgSeek),
+		datadash.YAxisAdaptive(flags.yAxisAdaptive),
+		datadash.ColorTheme(state.theme),
+		datadash.UseClock(state.clock),
+	)
+}
 
-		panic(text)
+func initBuffer(labels []string) {
+	//initialize one row per column, followed by the derived columns
+	if state.graphs == 0 {
+		state.rows = append(state.rows, newRow("Streaming Data...", 0))
+		state.rowNames = append(state.rowNames, "value")
+	}
+	for i := 1; i <= state.graphs; i++ {
+		label := fmt.Sprintf("col%d", i+1)
+		if i < len(labels) {
+			label = labels[i]
+		}
+		r := newRow(label, i)
+		attachBaseline(r, label)
+		groupOverlay(r)
+		state.rows = append(state.rows, r)
+		state.rowNames = append(state.rowNames, label)
+	}
+	for _, d := range state.derivations {
+		state.rows = append(state.rows, newRow(d.Name, len(state.rows)+1))
+		state.rowNames = append(state.rowNames, d.Name)
+	}
+}
+
+// initColumns maps the names usable in derive expressions to field indexes:
+// colN refers to the Nth field of a record and header labels which are valid
+// identifiers refer to their own column.
+func initColumns(labels []string, fields int) error {
+	for i := 0; i < fields; i++ {
+		state.columnIndex[fmt.Sprintf("col%d", i+1)] = i
+	}
+	for i, l := range labels {
+		if _, ok := state.columnIndex[strings.TrimSpace(l)]; !ok {
+			state.columnIndex[strings.TrimSpace(l)] = i
+		}
+	}
+	known := map[string]bool{}
+	for _, d := range state.derivations {
+		for _, v := range d.Vars() {
+			if _, ok := state.columnIndex[v]; !ok && !known[v] {
+				return fmt.Errorf("derive %s=%s: unknown column %q", d.Name, d.Expr, v)
+			}
+		}
+		known[d.Name] = true
 	}
+	return nil
+}
 
-	//if no matches the return nil
+// initTransforms assigns a counter transform to the rows named by the
+// --transform flags. Columns are referenced like in derive expressions.
+func initTransforms() error {
+	columns := state.graphs
+	if state.graphs == 0 {
+		columns = 1
+	}
+	state.counters = make([]*datadash.Counter, columns+len(state.derivations))
+	for _, def := range flags.transforms {
+		eq := strings.LastIndex(def, "=")
+		if eq < 0 {
+			return fmt.Errorf("transform %q: expected column=mode", def)
+		}
+		name := strings.TrimSpace(def[:eq])
+		c, err := datadash.NewCounter(strings.TrimSpace(def[eq+1:]))
+		if err != nil {
+			return fmt.Errorf("transform %q: %v", def, err)
+		}
+		row := -1
+		for i, d := range state.derivations {
+			if d.Name == name {
+				row = columns + i
+			}
+		}
+		if i, ok := state.columnIndex[name]; ok && row < 0 {
+			//the first field is the X-Axis label unless streaming
+			row = i - 1
+			if state.graphs == 0 {
+				row = i
+			}
+		}
+		if row < 0 || row >= len(state.counters) {
+			return fmt.Errorf("transform %q: unknown column %q", def, name)
+		}
+		state.counters[row] = c
+	}
+	return nil
+}
 
+// plotValues adds one value per row and writes the record to the sinks,
+// values of NaN (missing fields) are skipped.
+func plotValues(label string, values []float64) {
+	shown := displayLabel(label)
+	for i, val := range values {
+		if !math.IsNaN(val) {
+			state.rows[i].Update(val, shown, flags.avgSeek)
+		}
+	}
+	if state.sinks != nil {
+		//failures are counted and shown in the status bar
+		state.sinks.Write(label, state.rowNames, values)
+	}
+	if state.baseline != nil {
+		plotBaseline(label)
+	}
 }
 
-func initBuffer(records []string) {
-	//initialize the rows
-	stream = datadash.NewRow(ctx, "Streaming Data...", BUFFER_SIZE, 0, *scrollData, *avgLine, *yAxisAdaptive)
-	row1 = datadash.NewRow(ctx, "Row1...", BUFFER_SIZE, 1, *scrollData, *avgLine, *yAxisAdaptive)
-	row2 = datadash.NewRow(ctx, "Row2...", BUFFER_SIZE, 2, *scrollData, *avgLine, *yAxisAdaptive)
-	row3 = datadash.NewRow(ctx, "Row3...", BUFFER_SIZE, 3, *scrollData, *avgLine, *yAxisAdaptive)
-	row4 = datadash.NewRow(ctx, "Row4...", BUFFER_SIZE, 4, *scrollData, *avgLine, *yAxisAdaptive)
-	row5 = datadash.NewRow(ctx, "Row5...", BUFFER_SIZE, 5, *scrollData, *avgLine, *yAxisAdaptive)
+// displayLabel shortens labels which are timestamps of full precision, like
+// the ones of InfluxDB points, to the time of day shown on the X-Axis.
+func displayLabel(label string) string {
+	if t, err := time.Parse(time.RFC3339Nano, label); err == nil {
+		return t.Format("15:04:05")
+	}
+	return label
 }
 
-func parsePlotData(records []string) {
+func parsePlotData(ctx context.Context, records []string) {
 	var label string
 	var record []string
 
 	//streaming data mode or normal mode
-	if graphs == 0 {
+	if state.graphs == 0 {
 		record = records[0:]
 	} else {
 		label = records[0]
 		record = records[1:]
 	}
-	if *labelMode == "time" {
+	if flags.labelMode == "time" || label == "" {
 		//Use the time as a X-Axis labels
-		now := time.Now()
+		now := state.clock.Now()
 		label = fmt.Sprintf("%02d:%02d:%02d", now.Hour(), now.Minute(), now.Second())
 	}
+	if flags.debug {
+		fmt.Println("DEBUG:\tFull Record:", record)
+		fmt.Println("DEBUG:\tLabel Value:", label)
+	}
 
-	for i, x := range record {
-		if *debug {
-			fmt.Println("DEBUG:\tFull Record:", record)
+	//add the columns which appeared since the last record
+	if state.columnNames != nil && len(record) > len(state.rows)-len(state.derivations) {
+		if err := addColumns(ctx, state.columnNames()); err != nil {
+			fatal(fmt.Errorf("adding columns: %v", err))
+			return
 		}
-		switch i {
-		case 0:
-			if *debug {
-				fmt.Println("DEBUG:\tRecord[0]:", record[i])
-				fmt.Println("DEBUG:\tCount Value[i]:", i)
-				fmt.Println("DEBUG:\tRecord Value [x]:", x)
-				fmt.Println("DEBUG:\tLabel Value:", label)
-			}
-			val, _ := strconv.ParseFloat(strings.TrimSpace(record[i]), 64)
-			stream.Update(val, label, *avgSeek)
-			row1.Update(val, label, *avgSeek)
-
-		case 1:
-			if *debug {
-				fmt.Println("DEBUG:\tRecord[1]:", record[i])
-				fmt.Println("DEBUG:\tCount Value[i]:", i)
-				fmt.Println("DEBUG:\tRecord Value [x]:", x)
-				fmt.Println("DEBUG:\tLabel Value:", label)
-			}
-			val, _ := strconv.ParseFloat(strings.TrimSpace(record[i]), 64)
-			row2.Update(val, label, *avgSeek)
-
-		case 2:
-			if *debug {
-				fmt.Println("DEBUG:\tRecord[2]:", record[i])
-				fmt.Println("DEBUG:\tCount Value[i]:", i)
-				fmt.Println("DEBUG:\tRecord Value [x]:", x)
-				fmt.Println("DEBUG:\tLabel Value:", label)
-			}
-			val, _ := strconv.ParseFloat(strings.TrimSpace(record[i]), 64)
-			row3.Update(val, label, *avgSeek)
-
-		case 3:
-			if *debug {
-				fmt.Println("DEBUG:\tRecord[3]:", record[i])
-				fmt.Println("DEBUG:\tCount Value[i]:", i)
-				fmt.Println("DEBUG:\tRecord Value [x]:", x)
-				fmt.Println("DEBUG:\tLabel Value:", label)
-			}
-			val, _ := strconv.ParseFloat(strings.TrimSpace(record[i]), 64)
-			row4.Update(val, label, *avgSeek)
-
-		case 4:
-			if *debug {
-				fmt.Println("DEBUG:\tRecord[5]:", record[i])
-				fmt.Println("DEBUG:\tCount Value[i]:", i)
-				fmt.Println("DEBUG:\tRecord Value [x]:", x)
-				fmt.Println("DEBUG:\tLabel Value:", label)
-			}
-			val, _ := strconv.ParseFloat(strings.TrimSpace(record[i]), 64)
-			row5.Update(val, label, *avgSeek)
+	}
+
+	values := make([]float64, len(state.rows))
+	columns := len(state.rows) - len(state.derivations)
+	for i := 0; i < columns; i++ {
+		//missing and empty fields and cells which aren't numbers are not
+		//plotted
+		values[i] = math.NaN()
+		if i >= len(record) {
+			continue
+		}
+		if v, err := strconv.ParseFloat(strings.TrimSpace(record[i]), 64); err == nil {
+			values[i] = v
+		}
+	}
+
+	//evaluate the derived columns against the whole record, missing fields
+	//and cells which aren't numbers are NaN so the result isn't plotted
+	derived := make(map[string]float64, len(state.derivations))
+	lookup := func(name string) (float64, bool) {
+		if v, ok := derived[name]; ok {
+			return v, true
+		}
+		i, ok := state.columnIndex[name]
+		if !ok || i >= len(records) {
+			return math.NaN(), ok
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(records[i]), 64)
+		if err != nil {
+			return math.NaN(), true
+		}
+		return v, true
+	}
+	for i, d := range state.derivations {
+		val, err := d.Eval(lookup)
+		if err != nil || math.IsInf(val, 0) {
+			val = math.NaN()
+		}
+		if flags.debug {
+			fmt.Println("DEBUG:\tDerived Value", d.Name+":", val)
+		}
+		derived[d.Name] = val
+		values[columns+i] = val
+	}
+
+	//the label is the record's timestamp when it is one, else the arrival time
+	ts, ok := datadash.ParseTimestamp(label)
+	if !ok {
+		ts = state.clock.Now()
+	}
+	state.arrivalTime = !ok
+	for i, c := range state.counters {
+		if c != nil && !math.IsNaN(values[i]) {
+			values[i] = c.Next(values[i], ts)
 		}
 	}
 
+	if state.bucket != nil {
+		if l, aggregated, ok := state.bucket.Add(ts, values); ok {
+			plotValues(l, aggregated)
+		}
+		return
+	}
+	plotValues(label, values)
 }
 
 func readDataChannel(ctx context.Context) {
-	go periodic(ctx, *seekInterval, func() error {
+	periodic(ctx, flags.seekInterval, func() error {
+		if err := showQuality(); err != nil {
+			return err
+		}
 		var records []string
+		var ok bool
 		//remove a record from the channel
-		if *debug {
-			fmt.Println("DEBUG:\tRemoving record from channel.")
+		select {
+		case records, ok = <-state.dataChan:
+			if !ok {
+				//the input ended, close the last bucket
+				flushBucket()
+				return nil
+			}
+			if flags.debug {
+				fmt.Println("DEBUG:\tRemoved record from channel.")
+			}
+		default:
+			//close buckets of records timed by their arrival once they end
+			if state.bucket != nil && state.arrivalTime && state.bucket.Expired(state.clock.Now()) {
+				flushBucket()
+			}
+			return nil
 		}
-		records = <-dataChan
 		//add record to the buffer
-		if *debug {
+		if flags.debug {
 			fmt.Println("DEBUG:\tParsing line record:", records)
 		}
-		parsePlotData(records)
+		parsePlotData(ctx, records)
 		return nil
 	})
 }
 
-// periodic executes the provided closure periodically every interval.
-// Exits when the context expires.
+// flushBucket plots the open bucket, if any.
+func flushBucket() {
+	if state.bucket == nil {
+		return
+	}
+	if l, aggregated, ok := state.bucket.Flush(); ok {
+		plotValues(l, aggregated)
+	}
+}
+
+// periodic calls fn every interval of the clock until ctx is done, an error
+// returned by fn is fatal.
 func periodic(ctx context.Context, interval time.Duration, fn func() error) {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-	for {
-		select {
-		case <-ticker.C:
-			if err := fn(); err != nil {
-				panic(err)
-			}
-		case <-ctx.Done():
-			return
+	errc := datadash.Periodic(ctx, state.clock, interval, fn)
+	go func() {
+		if err := <-errc; err != nil {
+			fatal(err)
 		}
-	}
+	}()
 }
 
 // rotate returns a new slice with inputs rotated by step.
@@ -388,125 +959,392 @@ func rotate(inputs []float64, step int) []float64 {
 }
 
 func main() {
-	//setup vars for pause / resume
-	reset := true
-	slower := false
-	faster := false
-	interrupt := false
+	app.FatalIfError(run(), "")
+}
+
+// run shows the dashboard until it is quit or fails, the terminal is
+// restored before an error is returned.
+func run() error {
+	//the keyboard sets the pace of the reader goroutine
+	var p pacer
 
 	// Parse args and assign values
 	kingpin.Version("0.0.1")
-	kingpin.MustParse(app.Parse(os.Args[1:]))
-	if *debug {
-		fmt.Printf("DEBUG:\tRunning with: Delimiter: '%s'\nlabelMode: %s\nReDraw Interval: %s\nSeek Interval: %s\n, Scrolling: %t\nDisplay Average Line: %t\n yAxisAdaptive: %t\n", *delimiter, *labelMode, *redrawInterval, *seekInterval, *scrollData, *avgLine, *yAxisAdaptive)
+	kingpin.MustParse(parseArgs(os.Args[1:]))
+	reader, err := setup(datadash.RealClock)
+	defer closeInputs()
+	if err != nil || reader == nil {
+		return err
 	}
-	//define the reader type (Stdin or File based)
-	var reader *csv.Reader
-	// read file in or Stdin
-	if *inputFile != nil {
-		reader = csv.NewReader(bufio.NewReader(*inputFile))
-		//defer file.Close()
-	} else if !termutil.Isatty(os.Stdin.Fd()) {
-		reader = csv.NewReader(bufio.NewReader(os.Stdin))
-	} else {
-		return
-	}
-	reader.Comma = []rune(*delimiter)[0]
+	//the dashboard stops when quit or on a fatal error
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	state.stopDashboard = cancel
 
-	//read the first line as labels
-	labels, err := reader.Read()
+	//Initialize termbox in the color mode matching the terminal
+	t, err := termbox.New(termbox.ColorMode(state.depth.ColorMode()))
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("terminal: %v", err)
 	}
-	//read the second line as data
-	records, err := reader.Read()
-	if err != nil {
-		if err == io.EOF {
-			return
-		}
-		panic(err)
-	}
-	//calculate number of graphs (max 4)
-	graphs = len(records) - 1
+	defer t.Close()
 
-	//print data
-	if *debug {
-		fmt.Println("DEBUG:\tRecords Array:", records)
-		fmt.Println("DEBUG:\tNumber of Graphs:", graphs)
-		fmt.Println("DEBUG:\tLabels Array:", labels)
+	//configure the box / graph layout and start reading from the data channel
+	c, err := start(ctx, t)
+	if err != nil {
+		return err
 	}
 	// read from Reader (Stdin or File) into a dataChan
 	go func() {
 		for {
-			if reset == true {
-				time.Sleep(*seekInterval * 4)
-			}
-			if faster == true {
-				time.Sleep(*seekInterval * 1)
-
-			}
-			if slower == true {
-				time.Sleep(*seekInterval * 6)
-			}
-			if interrupt == true {
-				time.Sleep(10 * time.Second)
-				interrupt = false
-			}
+			time.Sleep(p.delay())
 			r, err := reader.Read()
 			if err != nil {
 				if err == io.EOF {
-					return
+					close(state.dataChan)
+				} else {
+					fatal(err)
 				}
-				panic(err)
+				return
+			}
+			select {
+			case state.dataChan <- r:
+			case <-ctx.Done():
+				return
 			}
-			dataChan <- r
 		}
 	}() //end read from stdin/file
-
-	//initialize the ring buffer and widgets
-	initBuffer(records)
-	//Initialize termbox in 256 color mode
-	t, err := termbox.New(termbox.ColorMode(terminalapi.ColorMode256))
-	if err != nil {
-		panic(err)
-	}
-	defer t.Close()
-
-	//configure the box / graph layout
-	ctx, cancel := context.WithCancel(context.Background())
-	c, err := layout(ctx, t, labels)
-	if err != nil {
-		panic(err)
-	}
-	//start reading from the data channel
-	readDataChannel(ctx)
 	//listen for keyboard events
 	keyboardevents := func(k *terminalapi.Keyboard) {
 		if k.Key == 'q' || k.Key == 'Q' {
 			cancel()
 		}
-		if k.Key == keyboard.KeyArrowLeft || k.Key == 'f' {
-			slower = true
-			faster = false
-			reset = false
+		p.key(k.Key)
+	}
+	if err := termdash.Run(ctx, t, c, termdash.KeyboardSubscriber(keyboardevents), termdash.RedrawInterval(flags.redrawInterval)); err != nil {
+		return err
+	}
+	select {
+	case err := <-state.fatalErrors:
+		return err
+	default:
+		return nil
+	}
+} //end run
+
+// paces of the reader goroutine, in seek intervals between records, and the
+// delay of a pause
+const (
+	paceNormal = 4
+	paceSlow   = 6
+	paceFast   = 1
+	pauseDelay = 10 * time.Second
+)
+
+// pacer paces the reading of records. The keyboard handler sets the pace
+// while the reader goroutine waits, so both are atomic.
+type pacer struct {
+	//pace is the number of seek intervals between records, 0 for the
+	//normal pace
+	pace int32
+	//paused delays the next record by pauseDelay
+	paused int32
+}
+
+// key changes the pace for the keys of the title: space resets it, left and
+// 'f' slow it down, right and 's' speed it up and 'p' pauses.
+func (p *pacer) key(k keyboard.Key) {
+	switch k {
+	case keyboard.KeySpace:
+		atomic.StoreInt32(&p.pace, paceNormal)
+	case keyboard.KeyArrowLeft, 'f':
+		atomic.StoreInt32(&p.pace, paceSlow)
+	case keyboard.KeyArrowRight, 's':
+		atomic.StoreInt32(&p.pace, paceFast)
+	case 'p':
+		atomic.StoreInt32(&p.paused, 1)
+	}
+}
+
+// delay returns the time to wait before reading the next record, a pause
+// only delays one record.
+func (p *pacer) delay() time.Duration {
+	pace := atomic.LoadInt32(&p.pace)
+	if pace == 0 {
+		pace = paceNormal
+	}
+	d := flags.seekInterval * time.Duration(pace)
+	if atomic.SwapInt32(&p.paused, 0) == 1 {
+		d += pauseDelay
+	}
+	return d
+}
+
+// parseArgs parses the command line args into new flags, with a new app.
+func parseArgs(args []string) (string, error) {
+	f := &commandFlags{}
+	app, flags = newApp(f), f
+	return app.Parse(args)
+}
+
+// setup starts a new run timed by clock: it loads the theme, derivations,
+// sinks and baseline configured by the flags, opens the input and creates
+// the rows of its columns. It returns the reader of the records of the
+// input, nil when there is nothing to read.
+func setup(clock datadash.Clock) (recordReader, error) {
+	state = newRunState(clock)
+	state.theme, _ = datadash.LookupTheme(flags.themeName)
+	state.depth = datadash.DetectColorDepth(os.Getenv("TERM"), os.Getenv("COLORTERM"), os.Getenv("NO_COLOR"))
+	if flags.colorDepth != "auto" {
+		state.depth, _ = datadash.ParseColorDepth(flags.colorDepth)
+	}
+	state.theme = state.theme.WithDepth(state.depth)
+	if flags.bucketWidth > 0 {
+		agg, err := datadash.ParseAggregate(flags.aggregate)
+		if err != nil {
+			return nil, err
 		}
-		if k.Key == keyboard.KeyArrowRight || k.Key == 's' {
-			faster = true
-			slower = false
-			reset = false
+		state.bucket = datadash.NewBucket(flags.bucketWidth, agg)
+	}
+	for _, def := range flags.deriveExprs {
+		d, err := datadash.ParseDerivation(def)
+		if err != nil {
+			return nil, err
 		}
-		if k.Key == 'p' {
-			interrupt = true
-			slower = false
-			faster = false
+		state.derivations = append(state.derivations, d)
+	}
+	if len(flags.sinkSpecs) > 0 {
+		state.sinks = datadash.NewTee()
+		for _, spec := range flags.sinkSpecs {
+			sink, err := datadash.NewSink(spec)
+			if err != nil {
+				return nil, err
+			}
+			state.sinks.Sinks = append(state.sinks.Sinks, sink)
 		}
-		if k.Key == keyboard.KeySpace {
-			reset = true
-			slower = false
-			faster = false
+		state.closers = append(state.closers, state.sinks)
+	}
+	if flags.baselineFile != "" {
+		var err error
+		if state.baseline, err = loadBaseline(flags.baselineFile); err != nil {
+			return nil, err
 		}
 	}
-	if err := termdash.Run(ctx, t, c, termdash.KeyboardSubscriber(keyboardevents), termdash.RedrawInterval(*redrawInterval)); err != nil {
-		panic(err)
+	if flags.debug {
+		fmt.Printf("DEBUG:\tColor Depth: %s\n", state.depth)
+		fmt.Printf("DEBUG:\tRunning with: Delimiter: '%s'\nlabelMode: %s\nReDraw Interval: %s\nSeek Interval: %s\n, Scrolling: %t\nDisplay Average Line: %t\n yAxisAdaptive: %t\n", flags.delimiter, flags.labelMode, flags.redrawInterval, flags.seekInterval, flags.scrollData, flags.avgLine, flags.yAxisAdaptive)
+	}
+	//define the input source (Stdin or File based)
+	var sourceName, location string
+	files := expandInputs(flags.inputFiles)
+	// read file in or Stdin
+	if len(files) == 1 {
+		sourceName, location = "file", files[0]
+	} else if len(files) > 1 {
+		//the files are opened when merged below
+	} else if !termutil.Isatty(os.Stdin.Fd()) {
+		sourceName = "stdin"
+	} else if flags.scrapeURL == "" && flags.statsdAddr == "" && flags.listenAddr == "" && flags.execCommand == "" && flags.pollURL == "" {
+		return nil, nil
 	}
-} //end main
+
+	//define the reader type (JSON, Command, Socket, StatsD, Prometheus, merged files or a source)
+	var reader recordReader
+	var labels []string
+	var fields int
+	if flags.pollURL != "" {
+		if len(flags.pollFields) == 0 {
+			return nil, fmt.Errorf("--poll requires at least one --field")
+		}
+		var paths []*datadash.JSONPath
+		for _, f := range flags.pollFields {
+			path, err := datadash.ParseJSONPath(f)
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, path)
+		}
+		poller := datadash.NewJSONPoller(flags.pollURL, flags.every, paths)
+		//the columns are known from the fields, no request is made yet
+		labels = append([]string{datadash.LabelColumn}, poller.Columns()...)
+		fields = len(labels)
+		reader = poller
+		state.statusParts = append(state.statusParts, func() string {
+			requests, errors, lastErr := poller.Status()
+			status := fmt.Sprintf("Requests: %d | Errors: %d", requests, errors)
+			if lastErr != nil {
+				status += " | Last error: " + lastErr.Error()
+			}
+			return status
+		})
+	} else if flags.execCommand != "" {
+		timeout := flags.execTimeout
+		if timeout <= 0 {
+			timeout = flags.every
+		}
+		command := datadash.NewCommandReader(flags.execCommand, flags.every, timeout, newColumnReader)
+		var err error
+		if labels, err = command.Header(); err != nil {
+			return nil, err
+		}
+		fields = len(labels)
+		reader = command
+		state.columnNames = command.Columns
+		state.statusParts = append(state.statusParts, func() string {
+			runs, failures, lastErr := command.Status()
+			status := fmt.Sprintf("Runs: %d | Failures: %d", runs, failures)
+			if lastErr != nil {
+				status += " | Last error: " + lastErr.Error()
+			}
+			return status
+		})
+	} else if flags.listenAddr != "" {
+		server, err := datadash.ListenSocket(flags.listenAddr, flags.sourceTag, newColumnReader)
+		if err != nil {
+			return nil, fmt.Errorf("listen: %v", err)
+		}
+		state.closers = append(state.closers, server)
+		fmt.Fprintf(os.Stderr, "Waiting for records on %s...\n", server.Addr())
+		if labels, err = server.Header(); err != nil {
+			return nil, fmt.Errorf("listen: %v", err)
+		}
+		fields = len(labels)
+		reader = server
+		state.columnNames = server.Columns
+		state.statusParts = append(state.statusParts, func() string {
+			conns, active, lastErr := server.Status()
+			status := fmt.Sprintf("Connections: %d | Active: %d", conns, active)
+			if lastErr != nil {
+				status += " | Last error: " + lastErr.Error()
+			}
+			return status
+		})
+	} else if flags.statsdAddr != "" {
+		server, err := datadash.NewStatsdServer(flags.statsdAddr, flags.every)
+		if err != nil {
+			return nil, fmt.Errorf("listen-statsd: %v", err)
+		}
+		state.closers = append(state.closers, server)
+		fmt.Fprintf(os.Stderr, "Waiting for StatsD metrics on %s...\n", server.Addr())
+		if labels, err = server.Header(); err != nil {
+			return nil, fmt.Errorf("listen-statsd: %v", err)
+		}
+		fields = len(labels)
+		reader = server
+		state.columnNames = server.Columns
+		state.statusParts = append(state.statusParts, func() string {
+			packets, invalid := server.Status()
+			return fmt.Sprintf("Packets: %d | Invalid metrics: %d", packets, invalid)
+		})
+	} else if flags.scrapeURL != "" {
+		if len(flags.metrics) == 0 {
+			return nil, fmt.Errorf("--scrape requires at least one --metric")
+		}
+		var selectors []*datadash.PromSelector
+		for _, m := range flags.metrics {
+			sel, err := datadash.ParsePromSelector(m)
+			if err != nil {
+				return nil, err
+			}
+			selectors = append(selectors, sel)
+		}
+		scraper := datadash.NewPromScraper(flags.scrapeURL, flags.every, selectors)
+		var err error
+		if labels, err = scraper.Header(); err != nil {
+			return nil, fmt.Errorf("scrape %s: %v", flags.scrapeURL, err)
+		}
+		if len(labels) < 2 {
+			return nil, fmt.Errorf("scrape %s: no metrics matched %s", flags.scrapeURL, strings.Join(flags.metrics, ", "))
+		}
+		fields = len(labels)
+		reader = scraper
+		state.columnNames = scraper.Columns
+		state.statusParts = append(state.statusParts, func() string {
+			scrapes, errors, lastErr := scraper.Status()
+			status := fmt.Sprintf("Scrapes: %d | Errors: %d | Skipped lines: %d", scrapes, errors, scraper.Skipped())
+			if lastErr != nil {
+				status += " | Last error: " + lastErr.Error()
+			}
+			return status
+		})
+	} else {
+		if flags.format == "logfmt" && flags.labelKey == "" {
+			flags.labelMode = "time"
+		}
+		if len(files) > 1 {
+			merged, err := mergeFiles(files)
+			if err != nil {
+				return nil, err
+			}
+			labels = merged.Header()
+			reader = merged
+			state.columnNames = merged.Columns
+		} else {
+			src, header, err := openSource(sourceName, location)
+			if err == io.EOF {
+				return nil, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			state.closers = append(state.closers, src)
+			if stream, ok := src.(*datadash.StreamSource); ok {
+				if rr, ok := stream.Reader().(*datadash.RegexReader); ok && !rr.HasLabel() {
+					flags.labelMode = "time"
+				}
+			}
+			if status := sourceStatus(src); status != nil {
+				state.statusParts = append(state.statusParts, status)
+			}
+			labels = header
+			reader = checkQuality(src, header)
+			state.columnNames = src.Columns
+		}
+		fields = len(labels)
+	}
+	if state.sinks != nil {
+		state.statusParts = append(state.statusParts, func() string {
+			records, errors, lastErr := state.sinks.Status()
+			status := fmt.Sprintf("Written: %d | Sink errors: %d", records, errors)
+			if lastErr != nil {
+				status += " | Last error: " + lastErr.Error()
+			}
+			return status
+		})
+	}
+	//calculate number of graphs
+	state.graphs = fields - 1
+	if err := initColumns(labels, fields); err != nil {
+		return nil, err
+	}
+	if err := initTransforms(); err != nil {
+		return nil, err
+	}
+
+	//print data
+	if flags.debug {
+		fmt.Println("DEBUG:\tNumber of Graphs:", state.graphs)
+		fmt.Println("DEBUG:\tLabels Array:", labels)
+	}
+	//initialize the ring buffer and widgets
+	initBuffer(labels)
+	return reader, nil
+}
+
+// start lays out the dashboard on t and plots the records sent to the data
+// channel until ctx is done.
+func start(ctx context.Context, t terminalapi.Terminal) (*container.Container, error) {
+	c, err := layout(ctx, t)
+	if err != nil {
+		return nil, fmt.Errorf("layout: %v", err)
+	}
+	state.dashboard = c
+	readDataChannel(ctx)
+	return c, nil
+}
+
+// closeInputs releases the inputs of the run.
+func closeInputs() {
+	for _, c := range state.closers {
+		c.Close()
+	}
+}
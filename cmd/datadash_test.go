@@ -0,0 +1,528 @@
+package main
+
+import (
+	"context"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mum4k/termdash"
+	"github.com/mum4k/termdash/keyboard"
+	"github.com/mum4k/termdash/private/event/eventqueue"
+	"github.com/mum4k/termdash/private/faketerm"
+
+	"github.com/keithknott26/datadash"
+)
+
+// harness runs the dashboard of the command on a fake terminal, set up from
+// the command line like main. Time only passes when the harness moves its
+// clock.
+type harness struct {
+	t      *testing.T
+	clock  *datadash.FakeClock
+	term   *faketerm.Terminal
+	ctrl   *termdash.Controller
+	reader recordReader
+}
+
+// newHarness parses the command line args, which name an input, and lays out
+// the dashboard on a fake terminal of the given size.
+func newHarness(t *testing.T, size image.Point, args ...string) *harness {
+	t.Helper()
+	if _, err := parseArgs(args); err != nil {
+		t.Fatal(err)
+	}
+	fake := datadash.NewFakeClock(time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC))
+	reader, err := setup(fake)
+	t.Cleanup(closeInputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reader == nil {
+		t.Fatal("no input")
+	}
+
+	term, err := faketerm.New(size, faketerm.WithEventQueue(eventqueue.New()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	state.stopDashboard = cancel
+	c, err := start(ctx, term)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctrl, err := termdash.NewController(term, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(ctrl.Close)
+	return &harness{t: t, clock: fake, term: term, ctrl: ctrl, reader: reader}
+}
+
+// feed passes the next n records of the input to the dashboard, one per seek
+// interval.
+func (h *harness) feed(n int) {
+	h.t.Helper()
+	for i := 0; i < n; i++ {
+		rec, err := h.reader.Read()
+		if err == io.EOF {
+			h.t.Fatalf("input ended after %d records", i)
+		}
+		if err != nil {
+			h.t.Fatal(err)
+		}
+		state.dataChan <- rec
+		h.clock.Advance(flags.seekInterval)
+	}
+}
+
+// end feeds the remaining records of the input and closes the channel like
+// the reader does at the end of the input.
+func (h *harness) end() {
+	h.t.Helper()
+	for {
+		rec, err := h.reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			h.t.Fatal(err)
+		}
+		state.dataChan <- rec
+		h.clock.Advance(flags.seekInterval)
+	}
+	close(state.dataChan)
+	h.clock.Advance(flags.seekInterval)
+}
+
+// screen lets the widgets update and returns the redrawn terminal, one line
+// per row of cells.
+func (h *harness) screen() string {
+	h.t.Helper()
+	//the status bar and the data quality panel are the slowest widgets,
+	//redrawn every 10 intervals
+	h.clock.Advance(flags.redrawInterval * 10)
+	if err := h.ctrl.Redraw(); err != nil {
+		h.t.Fatal(err)
+	}
+	return h.term.String()
+}
+
+// countBraille returns the number of cells drawn with braille patterns, used
+// by the line charts.
+func countBraille(screen string) int {
+	n := 0
+	for _, r := range screen {
+		if isBraille(r) {
+			n++
+		}
+	}
+	return n
+}
+
+func isBraille(r rune) bool {
+	return r >= 0x2800 && r <= 0x28ff
+}
+
+// chartLine returns the line n lines below the title of a chart, starting
+// after its Y axis.
+func chartLine(screen, title string, n int) string {
+	lines := strings.Split(screen, "\n")
+	for i, l := range lines {
+		start := strings.Index(l, title+" - 'q' Quit")
+		if start < 0 || i+n >= len(lines) {
+			continue
+		}
+		//the chart is drawn right of the statistics, its cells are runes
+		line := []rune(lines[i+n])[len([]rune(l[:start])):]
+		axis := strings.IndexRune(string(line), '│')
+		return strings.TrimSuffix(string(line)[axis+len("│"):], "│")
+	}
+	return ""
+}
+
+func firstRune(s string) rune {
+	for _, r := range s {
+		return r
+	}
+	return 0
+}
+
+func TestDashboardLineCharts(t *testing.T) {
+	h := newHarness(t, image.Point{X: 160, Y: 64}, "../tools/sampledata/5col")
+	empty := h.screen()
+	for _, title := range []string{"Lock Failures - 'q' Quit", "Case Exceptions - 'q' Quit", "Timeouts - 'q' Quit", "Responses - 'q' Quit"} {
+		if !strings.Contains(empty, title) {
+			t.Errorf("screen lacks the title %q:\n%s", title, empty)
+		}
+	}
+	if n := countBraille(empty); n != 0 {
+		t.Errorf("%d braille cells drawn before any record", n)
+	}
+
+	h.feed(3)
+	screen := h.screen()
+	for _, want := range []string{"Count:       3", "Time:        00:01", "Value:       4.00", "Max:         12.00", "Max:         308.00"} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("screen lacks %q:\n%s", want, screen)
+		}
+	}
+	//Lock Failures falls from 12 to 4: the line starts in the top left corner
+	//of the chart and ends lower on its right
+	top, bottom := chartLine(screen, "Lock Failures", 1), chartLine(screen, "Lock Failures", 6)
+	if countBraille(top) == 0 || !isBraille(firstRune(top)) {
+		t.Errorf("the top line of the chart %q doesn't start with the line", top)
+	}
+	if countBraille(bottom) == 0 || isBraille(firstRune(bottom)) {
+		t.Errorf("the lower line of the chart %q should only be reached by the end of the line", bottom)
+	}
+
+	h.feed(5)
+	screen = h.screen()
+	for _, want := range []string{"Count:       8", "Time:        00:06"} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("screen lacks %q:\n%s", want, screen)
+		}
+	}
+}
+
+func TestDashboardBarCharts(t *testing.T) {
+	h := newHarness(t, image.Point{X: 160, Y: 64}, "-g", "bar", "--derive", "total=col2+col3", "../tools/sampledata/5col")
+	h.feed(2)
+	screen := h.screen()
+	for _, want := range []string{"total - 'q' Quit", "Count:       2", "Max:         22.00"} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("screen lacks %q:\n%s", want, screen)
+		}
+	}
+	if !strings.Contains(screen, "308") || !strings.Contains(screen, "202") {
+		t.Errorf("bars of Responses lack their values 308 and 202:\n%s", screen)
+	}
+	if n := countBraille(screen); n != 0 {
+		t.Errorf("%d braille cells drawn by bar charts", n)
+	}
+}
+
+func TestDashboardQualityPanel(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "ragged.tsv")
+	data := "x\tlatency\terrors\n" +
+		"10:00:01\t5\t1\n" +
+		"10:00:02\t6\n" +
+		"10:00:03\t7\t2\t9\n" +
+		"10:00:03\t8\tn/a\n" +
+		"10:00:02\t9\t3\n"
+	if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h := newHarness(t, image.Point{X: 140, Y: 40}, name)
+	h.feed(1)
+	if screen := h.screen(); strings.Contains(screen, "Data Quality") {
+		t.Errorf("data quality panel shown before any problem:\n%s", screen)
+	}
+	h.feed(4)
+	screen := h.screen()
+	for _, want := range []string{
+		"Data Quality",
+		"Records: 5 | Ragged: 2 | Unparsable cells: errors 1 | Duplicate labels: 1 | Out of order: 1",
+		"line 3: 2 fields, expected 3: 10:00:02 6",
+		"line 4: 4 fields, expected 3: 10:00:03 7 2 9",
+		`line 5: duplicate label, errors is "n/a": 10:00:03 8 n/a`,
+		"line 6: out of order: 10:00:02 9 3",
+		//missing cells and cells which aren't numbers are skipped
+		"Count:       5",
+		"Count:       3",
+	} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("screen lacks %q:\n%s", want, screen)
+		}
+	}
+}
+
+func TestReadErrorLine(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "quotes.csv")
+	if err := os.WriteFile(name, []byte("x,latency\n1,5\n2,6\"ms\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseArgs([]string{"-d", ",", name}); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := setup(datadash.RealClock)
+	defer closeInputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.Read(); err != nil {
+		t.Fatal(err)
+	}
+	_, err = reader.Read()
+	if err == nil || !strings.Contains(err.Error(), "line 3") || !strings.HasPrefix(err.Error(), name+": ") {
+		t.Errorf("Read() error = %v, want the file name and line 3", err)
+	}
+}
+
+func TestDashboardHeaderless(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "latency.csv")
+	if err := os.WriteFile(name, []byte("10:00:01,5,1\n10:00:02,6,0\n10:00:03,7,2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h := newHarness(t, image.Point{X: 160, Y: 40}, name)
+	h.feed(3)
+	screen := h.screen()
+	for _, want := range []string{"col2 - 'q' Quit", "col3 - 'q' Quit", "Count:       3", "Max:         7.00"} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("screen lacks %q:\n%s", want, screen)
+		}
+	}
+}
+
+// TestDashboardWhitespace plots vmstat output, which repeats its banner and
+// header lines and has no label column.
+func TestDashboardWhitespace(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "vmstat.txt")
+	vmstat := "procs ---memory---\n r  b   free\n 1  0 812344\nprocs ---memory---\n r  b   free\n 2  0 812100\n 3  1 811900\n"
+	if err := os.WriteFile(name, []byte(vmstat), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h := newHarness(t, image.Point{X: 160, Y: 40}, "--whitespace", name)
+	h.feed(3)
+	screen := h.screen()
+	for _, want := range []string{"r - 'q' Quit", "free - 'q' Quit", "Count:       3", "Max:         3.00"} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("screen lacks %q:\n%s", want, screen)
+		}
+	}
+	if strings.Contains(screen, "Data Quality") {
+		t.Errorf("Data Quality panel shown for valid records:\n%s", screen)
+	}
+}
+
+// TestDashboardDerivedNaN checks derived values of ragged records and of
+// divisions by zero aren't plotted.
+func TestDashboardDerivedNaN(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "ratio.tsv")
+	if err := os.WriteFile(name, []byte("x\ta\tb\n1\t4\t0\n2\t6\n3\t8\t4\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h := newHarness(t, image.Point{X: 160, Y: 60}, "--derive", "ratio=a/b", name)
+	h.feed(3)
+	screen := h.screen()
+	for _, want := range []string{"ratio - 'q' Quit", "Count:       1", "Min:         2.00"} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("screen lacks %q:\n%s", want, screen)
+		}
+	}
+}
+
+func TestDeriveForwardReference(t *testing.T) {
+	if _, err := parseArgs([]string{"--derive", "total=double+1", "--derive", "double=col2*2", "../tools/sampledata/5col"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := setup(datadash.RealClock)
+	closeInputs()
+	if err == nil || !strings.Contains(err.Error(), `unknown column "double"`) {
+		t.Errorf("setup() = %v, want an error for the forward reference", err)
+	}
+}
+
+// TestParseArgsFresh checks that the flags of a parse don't leak into the
+// next one.
+func TestParseArgsFresh(t *testing.T) {
+	if _, err := parseArgs([]string{"--bucket", "1s", "--overlay", "--sink", "csv:out", "-d", ",", "a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseArgs([]string{"c"}); err != nil {
+		t.Fatal(err)
+	}
+	if flags.bucketWidth != 0 || flags.overlay || flags.sinkSpecs != nil || flags.delimiter != "" || len(flags.inputFiles) != 1 {
+		t.Errorf("flags = %+v after a second parse, want the defaults", *flags)
+	}
+	if flags.aggregate != "mean" || flags.seekInterval != 20*time.Millisecond {
+		t.Errorf("aggregate = %q, seek interval = %v, want the defaults", flags.aggregate, flags.seekInterval)
+	}
+}
+
+func TestSetupColors(t *testing.T) {
+	h := newHarness(t, image.Point{X: 160, Y: 64}, "--colors", "16", "../tools/sampledata/5col")
+	h.feed(1)
+	if state.depth != datadash.Colors16 {
+		t.Errorf("depth = %s, want 16", state.depth)
+	}
+	for _, c := range append([]int{state.theme.Border, state.theme.Text}, state.theme.Series...) {
+		if c >= 16 {
+			t.Errorf("theme color %d not mapped to 16 colors", c)
+		}
+	}
+}
+
+// TestDashboardBucketEOF checks the last bucket of records timed by their
+// labels is plotted at the end of the input.
+func TestDashboardBucketEOF(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "latency.tsv")
+	data := "x\tlatency\n10:00:00\t1\n10:00:00\t3\n10:00:01\t5\n10:00:01\t7\n"
+	if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h := newHarness(t, image.Point{X: 140, Y: 40}, "--bucket", "1s", "--aggregate", "max", name)
+	h.end()
+	screen := h.screen()
+	for _, want := range []string{"Count:       2", "Max:         7.00", "Min:         3.00"} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("screen lacks %q:\n%s", want, screen)
+		}
+	}
+	if strings.Contains(screen, "Data Quality") {
+		t.Errorf("records of a bucket reported as duplicates:\n%s", screen)
+	}
+}
+
+// TestDashboardInflux plots a batch of points sharing their time, which are
+// no duplicates, labeled with their time of day.
+func TestDashboardInflux(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "metrics.lp")
+	start := time.Date(2026, 1, 2, 10, 0, 0, 0, time.Local).UnixNano()
+	var lines []string
+	for i := int64(0); i < 3; i++ {
+		ts := strconv.FormatInt(start+i*1e9, 10)
+		lines = append(lines, "cpu,host=a usage="+strconv.FormatInt(10+i, 10)+" "+ts, "cpu,host=b usage=20 "+ts)
+	}
+	if err := os.WriteFile(name, []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h := newHarness(t, image.Point{X: 160, Y: 40}, "--format", "influx", name)
+	h.feed(6)
+	screen := h.screen()
+	for _, want := range []string{"cpu.usage{host=a} - 'q' Quit", "cpu.usage{host=b} - 'q' Quit", "Time:        10:00:02", "Max:         12.00"} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("screen lacks %q:\n%s", want, screen)
+		}
+	}
+	if strings.Contains(screen, "Data Quality") {
+		t.Errorf("points sharing their time reported as duplicates:\n%s", screen)
+	}
+}
+
+// TestDashboardMerge overlays the columns of merged files sharing a name and
+// checks the records of every file for data quality problems.
+func TestDashboardMerge(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.tsv": "x\tlatency\n10:00:01\t5\n10:00:02\t6\n",
+		"b.tsv": "x\tlatency\terrors\n10:00:01\t7\t0\n10:00:02\tn/a\t1\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := newHarness(t, image.Point{X: 160, Y: 40}, "--overlay", "--merge", "time", filepath.Join(dir, "a.tsv"), filepath.Join(dir, "b.tsv"))
+	h.end()
+	screen := h.screen()
+	for _, want := range []string{
+		"latency [a.tsv, b.tsv] - 'q' Quit",
+		"errors [b.tsv] - 'q' Quit",
+		"Records: 4 | Ragged: 0 | Unparsable cells: b.tsv/latency 1 | Duplicate labels: 0 | Out of order: 0",
+		`b.tsv: line 3: latency is "n/a": 10:00:02 n/a 1`,
+	} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("screen lacks %q:\n%s", want, screen)
+		}
+	}
+	if strings.Contains(screen, "a.tsv/latency") || strings.Contains(screen, "b.tsv/latency -") {
+		t.Errorf("overlaid columns drawn in graphs of their own:\n%s", screen)
+	}
+}
+
+// TestDashboardBaselineAlign compares records two seconds apart against a
+// baseline of one record per second, aligned record by record or by time.
+func TestDashboardBaselineAlign(t *testing.T) {
+	dir := t.TempDir()
+	current := filepath.Join(dir, "current.tsv")
+	baseline := filepath.Join(dir, "baseline.tsv")
+	if err := os.WriteFile(current, []byte("x\tlatency\n10:00:00\t10\n10:00:02\t10\n10:00:04\t10\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(baseline, []byte("x\tlatency\n09:00:00\t10\n09:00:01\t20\n09:00:02\t30\n09:00:03\t40\n09:00:04\t50\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		align string
+		want  []string
+	}{
+		//compared with 10, 20 and 30
+		{"offset", []string{"Baseline:    mean -50%", "p99 -67%"}},
+		//compared with 10, 30 and 50
+		{"time", []string{"Baseline:    mean -67%", "p99 -80%"}},
+	} {
+		h := newHarness(t, image.Point{X: 240, Y: 30}, "--baseline", baseline, "--baseline-align", tc.align, current)
+		h.end()
+		screen := h.screen()
+		for _, want := range tc.want {
+			if !strings.Contains(screen, want) {
+				t.Errorf("--baseline-align %s: screen lacks %q:\n%s", tc.align, want, screen)
+			}
+		}
+	}
+}
+
+func TestPacer(t *testing.T) {
+	if _, err := parseArgs([]string{"--seek-interval", "10ms"}); err != nil {
+		t.Fatal(err)
+	}
+	var p pacer
+	for _, tc := range []struct {
+		key  keyboard.Key
+		want time.Duration
+	}{
+		{0, 40 * time.Millisecond},
+		{keyboard.KeyArrowLeft, 60 * time.Millisecond},
+		{'p', 10060 * time.Millisecond},
+		{'x', 60 * time.Millisecond},
+		{'s', 10 * time.Millisecond},
+		{'f', 60 * time.Millisecond},
+		{keyboard.KeyArrowRight, 10 * time.Millisecond},
+		{keyboard.KeySpace, 40 * time.Millisecond},
+	} {
+		p.key(tc.key)
+		if d := p.delay(); d != tc.want {
+			t.Errorf("delay() after %v = %s, want %s", tc.key, d, tc.want)
+		}
+	}
+}
+
+// TestPacerConcurrent presses keys while the records are paced, run it with
+// -race.
+func TestPacerConcurrent(t *testing.T) {
+	if _, err := parseArgs([]string{"--seek-interval", "10ms"}); err != nil {
+		t.Fatal(err)
+	}
+	var p pacer
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			p.key([]keyboard.Key{'f', 's', 'p', keyboard.KeySpace}[i%4])
+		}
+	}()
+	pauses := 0
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			if p.delay() > pauseDelay {
+				pauses++
+			}
+		}
+	}()
+	wg.Wait()
+	if pauses > 250 {
+		t.Errorf("%d records paused by 250 key presses", pauses)
+	}
+}
@@ -0,0 +1,154 @@
+package datadash
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mum4k/termdash/terminal/terminalapi"
+)
+
+// ColorDepth is the number of colors a terminal is able to display.
+type ColorDepth int
+
+// supported color depths
+const (
+	ColorsNone ColorDepth = iota
+	Colors16
+	Colors256
+	ColorsTrueColor
+)
+
+var colorDepthNames = map[ColorDepth]string{
+	ColorsNone:      "none",
+	Colors16:        "16",
+	Colors256:       "256",
+	ColorsTrueColor: "truecolor",
+}
+
+func (d ColorDepth) String() string {
+	if n, ok := colorDepthNames[d]; ok {
+		return n
+	}
+	return fmt.Sprintf("ColorDepth:%d", int(d))
+}
+
+// ParseColorDepth parses one of "none", "16", "256" or "truecolor".
+func ParseColorDepth(s string) (ColorDepth, error) {
+	for d, n := range colorDepthNames {
+		if strings.EqualFold(s, n) {
+			return d, nil
+		}
+	}
+	return ColorsNone, fmt.Errorf("unknown color depth %q, must be one of: none, 16, 256, truecolor", s)
+}
+
+// DetectColorDepth guesses the color depth of the terminal from the values of
+// the TERM, COLORTERM and NO_COLOR environment variables. Terminals which are
+// not known to support more are assumed to display 16 colors.
+func DetectColorDepth(term, colorTerm, noColor string) ColorDepth {
+	term = strings.ToLower(term)
+	colorTerm = strings.ToLower(colorTerm)
+	switch {
+	case noColor != "":
+		return ColorsNone
+	case term == "" || term == "dumb":
+		return ColorsNone
+	case colorTerm == "truecolor" || colorTerm == "24bit" || strings.HasSuffix(term, "-direct"):
+		return ColorsTrueColor
+	case strings.Contains(term, "256color"):
+		return Colors256
+	}
+	return Colors16
+}
+
+// ColorMode returns the termdash color mode used to initialize the terminal.
+// The terminal backend draws with the 256 color palette, so true color
+// terminals use ColorMode256 as well.
+func (d ColorDepth) ColorMode() terminalapi.ColorMode {
+	if d >= Colors256 {
+		return terminalapi.ColorMode256
+	}
+	return terminalapi.ColorModeNormal
+}
+
+// WithDepth returns a copy of the theme with every color mapped to the nearest
+// color the given depth is able to display.
+func (t *Theme) WithDepth(d ColorDepth) *Theme {
+	m := func(n int) int {
+		return downsample(n, d)
+	}
+	nt := *t
+	nt.Series = make([]int, len(t.Series))
+	for i, c := range t.Series {
+		nt.Series[i] = m(c)
+	}
+	nt.Border = m(t.Border)
+	nt.Text = m(t.Text)
+	nt.Pointer = m(t.Pointer)
+	nt.Value = m(t.Value)
+	nt.Axes = m(t.Axes)
+	nt.XLabels = m(t.XLabels)
+	nt.YLabels = m(t.YLabels)
+	nt.Average = m(t.Average)
+	nt.Baseline = m(t.Baseline)
+	nt.BarValue = m(t.BarValue)
+	return &nt
+}
+
+// downsample maps the palette number n to the nearest color available at the
+// given depth.
+func downsample(n int, d ColorDepth) int {
+	if n < 0 || n > 255 {
+		return -1
+	}
+	switch d {
+	case ColorsNone:
+		return -1
+	case Colors16:
+		if n < 16 {
+			return n
+		}
+		r, g, b := paletteRGB(n)
+		best, bestDist := 0, -1
+		for i := 0; i < 16; i++ {
+			pr, pg, pb := paletteRGB(i)
+			dist := (r-pr)*(r-pr) + (g-pg)*(g-pg) + (b-pb)*(b-pb)
+			if bestDist < 0 || dist < bestDist {
+				best, bestDist = i, dist
+			}
+		}
+		return best
+	}
+	return n
+}
+
+// xterm16 holds the RGB values of the 16 standard Xterm colors.
+var xterm16 = [16][3]int{
+	{0, 0, 0}, {128, 0, 0}, {0, 128, 0}, {128, 128, 0},
+	{0, 0, 128}, {128, 0, 128}, {0, 128, 128}, {192, 192, 192},
+	{128, 128, 128}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{0, 0, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// paletteRGB returns the RGB value of an Xterm 256 palette number.
+func paletteRGB(n int) (int, int, int) {
+	switch {
+	case n < 16:
+		c := xterm16[n]
+		return c[0], c[1], c[2]
+	case n < 232:
+		// 6x6x6 color cube
+		level := func(v int) int {
+			if v == 0 {
+				return 0
+			}
+			return 55 + v*40
+		}
+		n -= 16
+		return level(n / 36), level(n / 6 % 6), level(n % 6)
+	default:
+		// grayscale ramp
+		v := 8 + (n-232)*10
+		return v, v, v
+	}
+}
@@ -0,0 +1,136 @@
+package datadash
+
+import (
+	"testing"
+
+	"github.com/mum4k/termdash/terminal/terminalapi"
+)
+
+func TestDetectColorDepth(t *testing.T) {
+	for _, tc := range []struct {
+		term, colorTerm, noColor string
+		want                     ColorDepth
+	}{
+		{"xterm-256color", "", "", Colors256},
+		{"screen-256color", "", "", Colors256},
+		{"tmux-256color", "", "", Colors256},
+		{"XTERM-256COLOR", "", "", Colors256},
+		{"xterm-256color", "truecolor", "", ColorsTrueColor},
+		{"xterm", "24bit", "", ColorsTrueColor},
+		{"xterm", "TrueColor", "", ColorsTrueColor},
+		{"xterm-direct", "", "", ColorsTrueColor},
+		{"xterm", "", "", Colors16},
+		{"screen", "", "", Colors16},
+		{"vt100", "", "", Colors16},
+		{"linux", "yes", "", Colors16},
+		{"", "", "", ColorsNone},
+		{"dumb", "truecolor", "", ColorsNone},
+		{"xterm-256color", "truecolor", "1", ColorsNone},
+	} {
+		if got := DetectColorDepth(tc.term, tc.colorTerm, tc.noColor); got != tc.want {
+			t.Errorf("DetectColorDepth(%q, %q, %q) = %s, want %s", tc.term, tc.colorTerm, tc.noColor, got, tc.want)
+		}
+	}
+}
+
+func TestParseColorDepth(t *testing.T) {
+	for _, d := range []ColorDepth{ColorsNone, Colors16, Colors256, ColorsTrueColor} {
+		got, err := ParseColorDepth(d.String())
+		if err != nil || got != d {
+			t.Errorf("ParseColorDepth(%q) = %s, %v, want %s", d.String(), got, err, d)
+		}
+	}
+	if got, err := ParseColorDepth("TrueColor"); err != nil || got != ColorsTrueColor {
+		t.Errorf("ParseColorDepth(%q) = %s, %v, want truecolor", "TrueColor", got, err)
+	}
+	for _, s := range []string{"", "8", "24bit", "256colors"} {
+		if _, err := ParseColorDepth(s); err == nil {
+			t.Errorf("ParseColorDepth(%q) succeeded, want an error", s)
+		}
+	}
+}
+
+func TestColorMode(t *testing.T) {
+	for _, tc := range []struct {
+		depth ColorDepth
+		want  terminalapi.ColorMode
+	}{
+		{ColorsNone, terminalapi.ColorModeNormal},
+		{Colors16, terminalapi.ColorModeNormal},
+		{Colors256, terminalapi.ColorMode256},
+		{ColorsTrueColor, terminalapi.ColorMode256},
+	} {
+		if got := tc.depth.ColorMode(); got != tc.want {
+			t.Errorf("%s.ColorMode() = %v, want %v", tc.depth, got, tc.want)
+		}
+	}
+}
+
+func TestDownsample(t *testing.T) {
+	for _, tc := range []struct {
+		n                          int
+		none, c16, c256, truecolor int
+	}{
+		//the 16 standard colors are kept
+		{0, -1, 0, 0, 0},
+		{9, -1, 9, 9, 9},
+		{15, -1, 15, 15, 15},
+		//colors of the cube map to the nearest standard color
+		{196, -1, 9, 196, 196},
+		{46, -1, 10, 46, 46},
+		{21, -1, 12, 21, 21},
+		{231, -1, 15, 231, 231},
+		{16, -1, 0, 16, 16},
+		{82, -1, 10, 82, 82},
+		{124, -1, 1, 124, 124},
+		//and so do the grays
+		{232, -1, 0, 232, 232},
+		{244, -1, 8, 244, 244},
+		{250, -1, 7, 250, 250},
+		//the default color and out of range numbers
+		{-1, -1, -1, -1, -1},
+		{256, -1, -1, -1, -1},
+	} {
+		for _, want := range []struct {
+			depth ColorDepth
+			n     int
+		}{{ColorsNone, tc.none}, {Colors16, tc.c16}, {Colors256, tc.c256}, {ColorsTrueColor, tc.truecolor}} {
+			if got := downsample(tc.n, want.depth); got != want.n {
+				t.Errorf("downsample(%d, %s) = %d, want %d", tc.n, want.depth, got, want.n)
+			}
+		}
+	}
+}
+
+func TestThemeWithDepth(t *testing.T) {
+	theme, err := LookupTheme("dark")
+	if err != nil {
+		t.Fatal(err)
+	}
+	series := append([]int(nil), theme.Series...)
+	for _, d := range []ColorDepth{ColorsNone, Colors16, Colors256} {
+		mapped := theme.WithDepth(d)
+		colors := append([]int{mapped.Border, mapped.Text, mapped.Pointer, mapped.Value, mapped.Axes,
+			mapped.XLabels, mapped.YLabels, mapped.Average, mapped.Baseline, mapped.BarValue}, mapped.Series...)
+		for _, c := range colors {
+			switch {
+			case d == ColorsNone && c != -1:
+				t.Errorf("WithDepth(none) kept the color %d", c)
+			case d == Colors16 && c >= 16:
+				t.Errorf("WithDepth(16) kept the color %d", c)
+			}
+		}
+		if d == Colors256 && mapped.Border != theme.Border {
+			t.Errorf("WithDepth(256) changed the border color from %d to %d", theme.Border, mapped.Border)
+		}
+		if len(mapped.Series) != len(series) {
+			t.Errorf("WithDepth(%s) has %d series colors, want %d", d, len(mapped.Series), len(series))
+		}
+	}
+	//the theme itself is left as it was
+	for i, c := range theme.Series {
+		if c != series[i] {
+			t.Fatalf("WithDepth() changed the theme's series colors to %v", theme.Series)
+		}
+	}
+}
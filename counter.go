@@ -0,0 +1,99 @@
+package datadash
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// counter transforms
+const (
+	CounterDelta = "delta"
+	CounterRate  = "rate"
+)
+
+// Counter converts the values of a monotonically increasing counter into the
+// difference between consecutive records (delta) or into a per second rate
+// (rate). A value smaller than its predecessor is treated as a counter reset,
+// in which case the new value is taken as the increase since the reset.
+type Counter struct {
+	Mode     string
+	prev     float64
+	prevTime time.Time
+	last     float64
+	seen     bool
+}
+
+// NewCounter returns a Counter for the "delta" or "rate" transform.
+func NewCounter(mode string) (*Counter, error) {
+	switch mode {
+	case CounterDelta, CounterRate:
+		return &Counter{Mode: mode}, nil
+	}
+	return nil, fmt.Errorf("unknown transform %q, must be %q or %q", mode, CounterDelta, CounterRate)
+}
+
+// Next returns the transformed value of v, observed at ts. The first value
+// has nothing to be compared with and returns NaN, which is not plotted. A
+// rate is only computed once ts advances, until then the previous rate is
+// repeated.
+func (c *Counter) Next(v float64, ts time.Time) float64 {
+	if !c.seen {
+		c.prev, c.prevTime, c.seen = v, ts, true
+		c.last = math.NaN()
+		return c.last
+	}
+	increase := v - c.prev
+	if increase < 0 {
+		// counter reset
+		increase = v
+	}
+	if c.Mode == CounterDelta {
+		c.prev, c.prevTime = v, ts
+		c.last = increase
+		return c.last
+	}
+	elapsed := ts.Sub(c.prevTime)
+	if elapsed < 0 && ts.Year() == 0 {
+		// time of day labels wrapped past midnight
+		elapsed += 24 * time.Hour
+	}
+	if elapsed <= 0 {
+		return c.last
+	}
+	c.prev, c.prevTime = v, ts
+	c.last = increase / elapsed.Seconds()
+	return c.last
+}
+
+// timestampLayouts are the label formats recognized by ParseTimestamp.
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006/01/02 15:04:05",
+	"15:04:05.999999999",
+	"15:04",
+}
+
+// ParseTimestamp parses an X-Axis label as a point in time. Dates, times of
+// day and unix epochs in seconds or milliseconds are recognized.
+func ParseTimestamp(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		switch {
+		case f >= 1e12:
+			return time.UnixMilli(int64(f)), true
+		case f >= 1e9:
+			return time.Unix(0, int64(f*1e9)), true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,103 @@
+package datadash
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// sameValue reports whether a and b are equal or both NaN.
+func sameValue(a, b float64) bool {
+	return a == b || math.IsNaN(a) && math.IsNaN(b)
+}
+
+func TestCounter(t *testing.T) {
+	start := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	type point struct {
+		v       float64
+		elapsed time.Duration
+		want    float64
+	}
+	for _, tc := range []struct {
+		name   string
+		mode   string
+		points []point
+	}{
+		{"delta", CounterDelta, []point{{10, 0, math.NaN()}, {15, 0, 5}, {15, 0, 0}, {30, 0, 15}}},
+		//after a reset the new value is the increase since the reset
+		{"delta reset", CounterDelta, []point{{100, 0, math.NaN()}, {120, 0, 20}, {5, 0, 5}, {10, 0, 5}}},
+		{"rate", CounterRate, []point{{100, 0, math.NaN()}, {120, 2 * time.Second, 10}, {150, 4 * time.Second, 15}}},
+		//the rate is repeated until the time advances
+		{"rate same time", CounterRate, []point{{100, 0, math.NaN()}, {110, 0, math.NaN()}, {120, time.Second, 20}, {130, time.Second, 20}, {160, 3 * time.Second, 20}}},
+		{"rate reset", CounterRate, []point{{100, 0, math.NaN()}, {200, 10 * time.Second, 10}, {30, 20 * time.Second, 3}}},
+	} {
+		c, err := NewCounter(tc.mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, p := range tc.points {
+			if got := c.Next(p.v, start.Add(p.elapsed)); !sameValue(got, p.want) {
+				t.Errorf("%s: Next(%v) #%d = %v, want %v", tc.name, p.v, i+1, got, p.want)
+			}
+		}
+	}
+	if _, err := NewCounter("derivative"); err == nil {
+		t.Error("NewCounter(derivative) succeeded, want an error")
+	}
+}
+
+// TestCounterMidnight computes a rate across midnight from labels which only
+// hold the time of day.
+func TestCounterMidnight(t *testing.T) {
+	c, err := NewCounter(CounterRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []struct {
+		label string
+		v     float64
+		want  float64
+	}{
+		{"23:59:58", 100, math.NaN()},
+		{"00:00:02", 120, 5},
+		{"00:00:04", 130, 5},
+	} {
+		ts, ok := ParseTimestamp(p.label)
+		if !ok {
+			t.Fatalf("ParseTimestamp(%q) failed", p.label)
+		}
+		if got := c.Next(p.v, ts); !sameValue(got, p.want) {
+			t.Errorf("Next(%v) at %s = %v, want %v", p.v, p.label, got, p.want)
+		}
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	for _, tc := range []struct {
+		label string
+		want  time.Time
+	}{
+		{"2026-01-02T10:00:00Z", time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)},
+		{"2026-01-02T10:00:00.25+02:00", time.Date(2026, 1, 2, 8, 0, 0, 250e6, time.UTC)},
+		{"2026-01-02 10:00:00", time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)},
+		{"2026-01-02 10:00:00.5", time.Date(2026, 1, 2, 10, 0, 0, 500e6, time.UTC)},
+		{"2026-01-02T10:00:01", time.Date(2026, 1, 2, 10, 0, 1, 0, time.UTC)},
+		{"2026/01/02 10:00:02", time.Date(2026, 1, 2, 10, 0, 2, 0, time.UTC)},
+		{" 10:00:03 ", time.Date(0, 1, 1, 10, 0, 3, 0, time.UTC)},
+		{"10:00:03.125", time.Date(0, 1, 1, 10, 0, 3, 125e6, time.UTC)},
+		{"10:04", time.Date(0, 1, 1, 10, 4, 0, 0, time.UTC)},
+		{"1767348000", time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)},
+		{"1767348000.5", time.Date(2026, 1, 2, 10, 0, 0, 500e6, time.UTC)},
+		{"1767348000123", time.Date(2026, 1, 2, 10, 0, 0, 123e6, time.UTC)},
+	} {
+		got, ok := ParseTimestamp(tc.label)
+		if !ok || !got.Equal(tc.want) {
+			t.Errorf("ParseTimestamp(%q) = %s, %t, want %s", tc.label, got, ok, tc.want)
+		}
+	}
+	for _, label := range []string{"", "web1", "12345", "25:00:00", "2026-13-01 10:00:00", "10:00:00 PM"} {
+		if got, ok := ParseTimestamp(label); ok {
+			t.Errorf("ParseTimestamp(%q) = %s, want no timestamp", label, got)
+		}
+	}
+}
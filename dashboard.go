@@ -0,0 +1,253 @@
+package datadash
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mum4k/termdash"
+	"github.com/mum4k/termdash/container"
+	"github.com/mum4k/termdash/terminal/termbox"
+	"github.com/mum4k/termdash/terminal/terminalapi"
+)
+
+// Panel is a part of a Dashboard drawn in a row of its own.
+type Panel interface {
+	// Start creates the panel's widgets, which are updated until ctx is done.
+	Start(ctx context.Context) error
+	// ContainerOptions returns the options placing the widgets in a container.
+	ContainerOptions() []container.Option
+}
+
+// panelsID identifies the container holding the panels of a Dashboard.
+const panelsID = "panels"
+
+// Dashboard draws live series on the terminal, stacked vertically in the
+// order they are added:
+//
+//	dash := datadash.New(datadash.Scroll(true))
+//	latency := dash.Series("latency", datadash.Line())
+//	go func() {
+//		for v := range measurements {
+//			latency.Push(v, "")
+//		}
+//	}()
+//	err := dash.Run(ctx)
+//
+// Series and panels may be added before or while the dashboard runs.
+type Dashboard struct {
+	opts []Option
+	cfg  *config
+
+	mu        sync.Mutex
+	panels    []Panel
+	series    int
+	running   bool
+	ctx       context.Context
+	cancel    context.CancelFunc
+	container *container.Container
+	// err stops the running dashboard, it is returned by Run
+	err error
+}
+
+// New returns a Dashboard, the options are the defaults of its series.
+func New(opts ...Option) *Dashboard {
+	return &Dashboard{opts: opts, cfg: newConfig(opts)}
+}
+
+// Series adds a series drawn with the dashboard's options followed by opts.
+// When it is added to a running dashboard which fails to lay it out, the
+// dashboard stops and Run returns the error.
+func (d *Dashboard) Series(name string, opts ...Option) *Series {
+	d.mu.Lock()
+	d.series++
+	id := d.series
+	d.mu.Unlock()
+
+	c := newConfig(append(append([]Option{SeriesID(id)}, d.opts...), opts...))
+	if c.theme == nil {
+		c.theme = d.theme()
+	}
+	s := &Series{
+		Name:           name,
+		row:            NewRow(name, SeriesID(c.id), BufferSize(c.bufferSize), Scroll(c.scroll), AverageLine(c.average, 0), YAxisAdaptive(c.yAxisAdaptive), ColorTheme(c.theme), UseClock(c.clock)),
+		graphType:      c.graphType,
+		averageSeek:    c.averageSeek,
+		redrawInterval: c.redrawInterval,
+	}
+	//starting a series can't fail, only laying out a running dashboard can
+	if err := d.Add(s); err != nil {
+		d.stop(err)
+	}
+	return s
+}
+
+// stop stops the running dashboard because of err, which Run returns. Only
+// the first error is kept.
+func (d *Dashboard) stop(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.cancel == nil {
+		return
+	}
+	if d.err == nil {
+		d.err = err
+	}
+	d.cancel()
+}
+
+// Add adds a panel below the others. When the dashboard is running the panel
+// is started right away.
+func (d *Dashboard) Add(p Panel) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.panels = append(d.panels, p)
+	if d.container == nil {
+		return nil
+	}
+	if err := p.Start(d.ctx); err != nil {
+		return err
+	}
+	return d.container.Update(panelsID, d.layout()...)
+}
+
+// Run draws the dashboard until ctx is done or 'q' is pressed. A dashboard
+// runs once at a time.
+func (d *Dashboard) Run(ctx context.Context) error {
+	d.mu.Lock()
+	if d.running {
+		d.mu.Unlock()
+		return errors.New("datadash: dashboard is already running")
+	}
+	d.running = true
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		d.running = false
+		d.mu.Unlock()
+	}()
+
+	t := d.cfg.terminal
+	if t == nil {
+		tb, err := termbox.New(termbox.ColorMode(d.depth().ColorMode()))
+		if err != nil {
+			return err
+		}
+		defer tb.Close()
+		t = tb
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	d.mu.Lock()
+	for _, p := range d.panels {
+		if err := p.Start(ctx); err != nil {
+			d.mu.Unlock()
+			return err
+		}
+	}
+	c, err := container.New(t, append([]container.Option{container.ID(panelsID)}, d.layout()...)...)
+	if err != nil {
+		d.mu.Unlock()
+		return err
+	}
+	d.ctx, d.cancel, d.container, d.err = ctx, cancel, c, nil
+	d.mu.Unlock()
+
+	quit := func(k *terminalapi.Keyboard) {
+		if k.Key == 'q' || k.Key == 'Q' {
+			cancel()
+		}
+	}
+	err = termdash.Run(ctx, t, c, termdash.KeyboardSubscriber(quit), termdash.RedrawInterval(d.cfg.redrawInterval))
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err == nil {
+		err = d.err
+	}
+	d.ctx, d.cancel, d.container, d.err = nil, nil, nil, nil
+	return err
+}
+
+// layout returns the options stacking the panels. Caller must hold d.mu.
+func (d *Dashboard) layout() []container.Option {
+	panels := make([][]container.Option, 0, len(d.panels))
+	for _, p := range d.panels {
+		panels = append(panels, p.ContainerOptions())
+	}
+	return Stack(panels...)
+}
+
+// theme returns the dashboard's theme mapped to the color depth of the
+// terminal.
+func (d *Dashboard) theme() *Theme {
+	t := d.cfg.theme
+	if t == nil {
+		t = themes[DefaultTheme]
+	}
+	return t.WithDepth(d.depth())
+}
+
+func (d *Dashboard) depth() ColorDepth {
+	if d.cfg.depth >= 0 {
+		return d.cfg.depth
+	}
+	return DetectColorDepth(os.Getenv("TERM"), os.Getenv("COLORTERM"), os.Getenv("NO_COLOR"))
+}
+
+// Stack splits the available space horizontally into equally sized rows, one
+// per panel.
+func Stack(panels ...[]container.Option) []container.Option {
+	switch len(panels) {
+	case 0:
+		return nil
+	case 1:
+		return panels[0]
+	}
+	half := len(panels) / 2
+	return []container.Option{
+		container.SplitHorizontal(
+			container.Top(Stack(panels[:half]...)...),
+			container.Bottom(Stack(panels[half:]...)...),
+			container.SplitPercent(half*100/len(panels)),
+		),
+	}
+}
+
+// Series is a named series of values drawn by a Dashboard, along with its
+// statistics.
+type Series struct {
+	Name           string
+	row            *Row
+	graphType      string
+	averageSeek    int
+	redrawInterval time.Duration
+}
+
+// Push adds a value labelled label on the X-Axis, an empty label is replaced
+// with the current time. It is safe to call from any goroutine.
+func (s *Series) Push(v float64, label string) {
+	if label == "" {
+		label = s.row.clock().Now().Format("15:04:05")
+	}
+	s.row.Update(v, label, s.averageSeek)
+}
+
+// Row returns the row drawing the series.
+func (s *Series) Row() *Row {
+	return s.row
+}
+
+// Start creates the widgets of the series.
+func (s *Series) Start(ctx context.Context) error {
+	s.row.Context = ctx
+	return s.row.InitWidgets(ctx, s.graphType, s.Name, s.redrawInterval, s.redrawInterval)
+}
+
+// ContainerOptions returns the options placing the statistics and the graph
+// of the series side by side.
+func (s *Series) ContainerOptions() []container.Option {
+	return s.row.ContainerOptions(s.row.Context, s.graphType)
+}
@@ -0,0 +1,141 @@
+package datadash_test
+
+import (
+	"context"
+	"image"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mum4k/termdash/cell"
+	"github.com/mum4k/termdash/container"
+	"github.com/mum4k/termdash/private/event/eventqueue"
+	"github.com/mum4k/termdash/private/faketerm"
+	"github.com/mum4k/termdash/terminal/terminalapi"
+
+	"github.com/keithknott26/datadash"
+)
+
+// started is a panel telling when the dashboard started its panels.
+type started chan struct{}
+
+func (p started) Start(ctx context.Context) error {
+	close(p)
+	return nil
+}
+
+func (p started) ContainerOptions() []container.Option {
+	return nil
+}
+
+// lockedTerminal is a fake terminal which can be read while it is drawn. A
+// redraw caused by a key may still be drawing when Run returns, and
+// faketerm.Terminal.String doesn't lock the cells.
+type lockedTerminal struct {
+	*faketerm.Terminal
+	mu sync.Mutex
+}
+
+func (t *lockedTerminal) SetCell(p image.Point, r rune, opts ...cell.Option) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.Terminal.SetCell(p, r, opts...)
+}
+
+func (t *lockedTerminal) Clear(opts ...cell.Option) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.Terminal.Clear(opts...)
+}
+
+func (t *lockedTerminal) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.Terminal.String()
+}
+
+// TestDashboard runs a dashboard on a fake terminal, pushes values to its
+// series and quits it with 'q'.
+func TestDashboard(t *testing.T) {
+	events := eventqueue.New()
+	fake, err := faketerm.New(image.Point{X: 200, Y: 40}, faketerm.WithEventQueue(events))
+	if err != nil {
+		t.Fatal(err)
+	}
+	term := &lockedTerminal{Terminal: fake}
+	clock := datadash.NewFakeClock(time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC))
+	dash := datadash.New(datadash.Terminal(term), datadash.UseClock(clock), datadash.RedrawInterval(10*time.Millisecond))
+	latency := dash.Series("latency", datadash.Line())
+	queue := dash.Series("queue depth", datadash.Bar())
+	ready := make(started)
+	if err := dash.Add(ready); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- dash.Run(context.Background()) }()
+	//the widgets of the series are created once the dashboard runs
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the panels were not started")
+	}
+	if err := dash.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "already running") {
+		t.Errorf("second Run() = %v, want an error", err)
+	}
+
+	for i, v := range []float64{12, 30, 18} {
+		latency.Push(v, "")
+		queue.Push(float64(i+1), "10:00:0"+string(rune('1'+i)))
+		clock.Advance(time.Second)
+	}
+	//let the dashboard redraw the updated widgets
+	time.Sleep(100 * time.Millisecond)
+	events.Push(&terminalapi.Keyboard{Key: 'q'})
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("'q' didn't stop the dashboard")
+	}
+
+	screen := term.String()
+	for _, want := range []string{
+		"latency - 'q' Quit",
+		"queue depth - 'q' Quit",
+		"Count:       3",
+		"Max:         30.00",
+		"Mean:        20.00",
+		"Time:        10:00:03",
+		"Value:       3.00",
+	} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("screen lacks %q:\n%s", want, screen)
+		}
+	}
+}
+
+// TestDashboardCancel stops a dashboard with its context, after which it can
+// run again.
+func TestDashboardCancel(t *testing.T) {
+	term, err := faketerm.New(image.Point{X: 80, Y: 20}, faketerm.WithEventQueue(eventqueue.New()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dash := datadash.New(datadash.Terminal(term), datadash.Colors(datadash.ColorsNone), datadash.ColorTheme(&datadash.Theme{}))
+	dash.Series("latency").Push(1, "a")
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		err := dash.Run(ctx)
+		cancel()
+		if err != nil {
+			t.Fatalf("Run() #%d: %v", i+1, err)
+		}
+	}
+	if !strings.Contains(term.String(), "latency - 'q' Quit") {
+		t.Errorf("screen lacks the series:\n%s", term.String())
+	}
+}
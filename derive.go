@@ -0,0 +1,264 @@
+package datadash
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Derivation is a series computed from the other columns of a record, for
+// example "rate=col3/col2*100". Expressions support numbers, variables, the
+// + - * / operators, unary minus and parentheses.
+type Derivation struct {
+	Name string
+	Expr string
+	root exprNode
+	vars []string
+}
+
+// ParseDerivation parses a "name=expression" definition.
+func ParseDerivation(def string) (*Derivation, error) {
+	eq := strings.Index(def, "=")
+	if eq < 0 {
+		return nil, fmt.Errorf("derive %q: expected name=expression", def)
+	}
+	name := strings.TrimSpace(def[:eq])
+	expr := strings.TrimSpace(def[eq+1:])
+	if !isIdent(name) {
+		return nil, fmt.Errorf("derive %q: invalid name %q", def, name)
+	}
+	p := &exprParser{src: expr}
+	p.next()
+	root, err := p.parseExpr()
+	if err == nil && p.tok.kind != tokEOF {
+		err = fmt.Errorf("unexpected %q at offset %d", p.tok.text, p.tok.pos)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("derive %q: %v", def, err)
+	}
+	return &Derivation{Name: name, Expr: expr, root: root, vars: p.vars}, nil
+}
+
+// Vars returns the variables referenced by the expression.
+func (d *Derivation) Vars() []string {
+	return d.vars
+}
+
+// Eval evaluates the expression, resolving variables with lookup. Division by
+// zero yields NaN.
+func (d *Derivation) Eval(lookup func(name string) (float64, bool)) (float64, error) {
+	return d.root.eval(lookup)
+}
+
+type exprNode interface {
+	eval(lookup func(string) (float64, bool)) (float64, error)
+}
+
+type numNode float64
+
+func (n numNode) eval(func(string) (float64, bool)) (float64, error) {
+	return float64(n), nil
+}
+
+type varNode string
+
+func (n varNode) eval(lookup func(string) (float64, bool)) (float64, error) {
+	v, ok := lookup(string(n))
+	if !ok {
+		return 0, fmt.Errorf("unknown variable %q", string(n))
+	}
+	return v, nil
+}
+
+type negNode struct {
+	x exprNode
+}
+
+func (n negNode) eval(lookup func(string) (float64, bool)) (float64, error) {
+	v, err := n.x.eval(lookup)
+	return -v, err
+}
+
+type binNode struct {
+	op   byte
+	l, r exprNode
+}
+
+func (n binNode) eval(lookup func(string) (float64, bool)) (float64, error) {
+	l, err := n.l.eval(lookup)
+	if err != nil {
+		return 0, err
+	}
+	r, err := n.r.eval(lookup)
+	if err != nil {
+		return 0, err
+	}
+	switch n.op {
+	case '+':
+		return l + r, nil
+	case '-':
+		return l - r, nil
+	case '*':
+		return l * r, nil
+	default:
+		if r == 0 {
+			return math.NaN(), nil
+		}
+		return l / r, nil
+	}
+}
+
+// token kinds
+const (
+	tokEOF = iota
+	tokNum
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind int
+	text string
+	pos  int
+}
+
+// exprParser is a recursive descent parser for derivation expressions:
+//
+//	expr   = term { ("+" | "-") term }
+//	term   = factor { ("*" | "/") factor }
+//	factor = number | ident | "-" factor | "(" expr ")"
+type exprParser struct {
+	src  string
+	pos  int
+	tok  token
+	vars []string
+}
+
+func (p *exprParser) next() {
+	for p.pos < len(p.src) {
+		c, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		if !unicode.IsSpace(c) {
+			break
+		}
+		p.pos += size
+	}
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokEOF, pos: start}
+		return
+	}
+	c, size := utf8.DecodeRuneInString(p.src[p.pos:])
+	switch {
+	case unicode.IsDigit(c) || c == '.':
+		p.scan(func(c rune) bool { return unicode.IsDigit(c) || c == '.' })
+		p.tok = token{kind: tokNum, text: p.src[start:p.pos], pos: start}
+	case unicode.IsLetter(c) || c == '_':
+		p.scan(isIdentRune)
+		p.tok = token{kind: tokIdent, text: p.src[start:p.pos], pos: start}
+	default:
+		p.pos += size
+		p.tok = token{kind: tokOp, text: p.src[start:p.pos], pos: start}
+	}
+}
+
+// scan advances past the runes for which accept returns true.
+func (p *exprParser) scan(accept func(rune) bool) {
+	for p.pos < len(p.src) {
+		c, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		if !accept(c) {
+			return
+		}
+		p.pos += size
+	}
+}
+
+func (p *exprParser) parseExpr() (exprNode, error) {
+	l, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokOp && (p.tok.text == "+" || p.tok.text == "-") {
+		op := p.tok.text[0]
+		p.next()
+		r, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		l = binNode{op: op, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *exprParser) parseTerm() (exprNode, error) {
+	l, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokOp && (p.tok.text == "*" || p.tok.text == "/") {
+		op := p.tok.text[0]
+		p.next()
+		r, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		l = binNode{op: op, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *exprParser) parseFactor() (exprNode, error) {
+	tok := p.tok
+	switch {
+	case tok.kind == tokNum:
+		p.next()
+		v, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at offset %d", tok.text, tok.pos)
+		}
+		return numNode(v), nil
+	case tok.kind == tokIdent:
+		p.next()
+		p.vars = append(p.vars, tok.text)
+		return varNode(tok.text), nil
+	case tok.kind == tokOp && tok.text == "-":
+		p.next()
+		x, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		return negNode{x: x}, nil
+	case tok.kind == tokOp && tok.text == "(":
+		p.next()
+		x, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokOp || p.tok.text != ")" {
+			return nil, fmt.Errorf("missing ')' at offset %d", p.tok.pos)
+		}
+		p.next()
+		return x, nil
+	case tok.kind == tokEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d", tok.text, tok.pos)
+}
+
+func isIdentRune(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_'
+}
+
+func isIdent(s string) bool {
+	if c, _ := utf8.DecodeRuneInString(s); s == "" || unicode.IsDigit(c) {
+		return false
+	}
+	for _, c := range s {
+		if !isIdentRune(c) {
+			return false
+		}
+	}
+	return true
+}
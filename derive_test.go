@@ -0,0 +1,82 @@
+package datadash
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestDerivationEval(t *testing.T) {
+	vars := map[string]float64{"col2": 4, "col3": 2, "latency": 10, "errors": 0, "débit": 6, "延迟": 3}
+	lookup := func(name string) (float64, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+	for _, tc := range []struct {
+		def  string
+		want float64
+	}{
+		{"a=1+2*3", 7},
+		{"a=(1+2)*3", 9},
+		{"a=8/4/2", 1},
+		{"a=10-4-3", 3},
+		{"a=-col2+1", -3},
+		{"a=-(col2+1)", -5},
+		{"a=2*-col3", -4},
+		{"a=col2/col3*100", 200},
+		{"a=latency/col3", 5},
+		{"a = latency\t*\t2 ", 20},
+		{"a=.5+1.5", 2},
+		{"a=latency/errors", math.NaN()},
+		//identifiers may use any letters
+		{"a=débit*2", 12},
+		{"a=延迟+débit", 9},
+		{"débit_par_延迟 = débit / 延迟", 2},
+	} {
+		d, err := ParseDerivation(tc.def)
+		if err != nil {
+			t.Errorf("ParseDerivation(%q): %v", tc.def, err)
+			continue
+		}
+		got, err := d.Eval(lookup)
+		if err != nil || !(got == tc.want || math.IsNaN(got) && math.IsNaN(tc.want)) {
+			t.Errorf("%s = %v, %v, want %v", tc.def, got, err, tc.want)
+		}
+	}
+}
+
+func TestDerivationVars(t *testing.T) {
+	d, err := ParseDerivation("rate=col3/(col2+total)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(d.Vars(), ","); got != "col3,col2,total" || d.Name != "rate" || d.Expr != "col3/(col2+total)" {
+		t.Errorf("ParseDerivation() = %s=%s with vars %s", d.Name, d.Expr, got)
+	}
+	//a reference to a derivation defined later is unknown when evaluated
+	_, err = d.Eval(func(name string) (float64, bool) { return 1, name != "total" })
+	if err == nil || !strings.Contains(err.Error(), `unknown variable "total"`) {
+		t.Errorf("Eval() with an unknown variable = %v", err)
+	}
+}
+
+func TestParseDerivationErrors(t *testing.T) {
+	for _, tc := range []struct {
+		def, err string
+	}{
+		{"col2+col3", "expected name=expression"},
+		{"1a=col2", "invalid name"},
+		{"a=", "unexpected end of expression"},
+		{"a=(col2+1", "missing ')'"},
+		{"a=col2+*col3", `unexpected "*"`},
+		{"a=col2 col3", `unexpected "col3"`},
+		{"a=1.2.3", `invalid number "1.2.3"`},
+		{"a=col2%2", `unexpected "%"`},
+		{"a=débit×2", `unexpected "×" at offset 6`},
+		{"٣a=col2", "invalid name"},
+	} {
+		if _, err := ParseDerivation(tc.def); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("ParseDerivation(%q) = %v, want an error containing %q", tc.def, err, tc.err)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package datadash_test
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/keithknott26/datadash"
+)
+
+func Example() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	dash := datadash.New(datadash.Scroll(true), datadash.AverageLine(true, 100))
+	latency := dash.Series("latency", datadash.Line())
+	queue := dash.Series("queue depth", datadash.Bar())
+	go func() {
+		for range time.Tick(100 * time.Millisecond) {
+			latency.Push(20+rand.Float64()*5, "")
+			queue.Push(float64(rand.Intn(10)), "")
+		}
+	}()
+	if err := dash.Run(ctx); err != nil {
+		panic(err)
+	}
+}
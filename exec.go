@@ -0,0 +1,138 @@
+package datadash
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CommandReader runs a shell command every interval and reads the records it
+// prints, like `watch`. The output of every run is read with its own
+// ColumnReader and columns are merged by name across runs. Failed runs are
+// counted and retried at the next interval.
+type CommandReader struct {
+	Command   string
+	Interval  time.Duration
+	Timeout   time.Duration
+	newReader func(io.Reader) (ColumnReader, error)
+	columns   *columnSet
+	queue     [][]string
+	next      time.Time
+
+	mu       sync.Mutex
+	runs     int
+	failures int
+	lastErr  error
+}
+
+// NewCommandReader returns a CommandReader running command with `sh -c`. A
+// run taking longer than timeout is killed.
+func NewCommandReader(command string, interval, timeout time.Duration, newReader func(io.Reader) (ColumnReader, error)) *CommandReader {
+	return &CommandReader{
+		Command:   command,
+		Interval:  interval,
+		Timeout:   timeout,
+		newReader: newReader,
+		columns:   newColumnSet(),
+	}
+}
+
+// Header runs the command once and returns the label column followed by the
+// columns of its output. The records of that run are returned by Read.
+func (c *CommandReader) Header() ([]string, error) {
+	if err := c.run(); err != nil {
+		return nil, err
+	}
+	return append([]string{LabelColumn}, c.Columns()...), nil
+}
+
+// Read returns the next record, running the command when the records of the
+// previous run have all been read.
+func (c *CommandReader) Read() ([]string, error) {
+	for len(c.queue) == 0 {
+		time.Sleep(time.Until(c.next))
+		c.run()
+	}
+	record := c.queue[0]
+	c.queue = c.queue[1:]
+	return record, nil
+}
+
+// Columns returns the names of the columns seen so far. It is safe to call
+// concurrently with Read.
+func (c *CommandReader) Columns() []string {
+	return c.columns.Names()
+}
+
+// Status returns the number of runs, of failed runs and the last error. It is
+// safe to call concurrently with Read.
+func (c *CommandReader) Status() (runs, failures int, lastErr error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.runs, c.failures, c.lastErr
+}
+
+func (c *CommandReader) run() error {
+	c.next = time.Now().Add(c.Interval)
+	records, err := c.exec()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.runs++
+	if err != nil {
+		c.failures++
+		c.lastErr = err
+		return err
+	}
+	c.queue = append(c.queue, records...)
+	return nil
+}
+
+func (c *CommandReader) exec() ([][]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", c.Command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	//only the shell is killed on timeout, the commands it started may hold
+	//on to the output, which is closed shortly after
+	cmd.WaitDelay = 100 * time.Millisecond
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("exec: timed out after %s", c.Timeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("exec: %v: %s", err, strings.SplitN(msg, "\n", 2)[0])
+		}
+		return nil, fmt.Errorf("exec: %v", err)
+	}
+	r, err := c.newReader(&stdout)
+	if err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("exec: no output")
+		}
+		return nil, fmt.Errorf("exec: %v", err)
+	}
+	var records [][]string
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("exec: %v", err)
+		}
+		if len(record) > 0 {
+			records = append(records, c.columns.Merge("", r.Columns(), record))
+		}
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("exec: no records in output")
+	}
+	return records, nil
+}
@@ -0,0 +1,76 @@
+package datadash
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTabReader(r io.Reader) (ColumnReader, error) {
+	return NewCSVReader(r, '\t')
+}
+
+func TestCommandReader(t *testing.T) {
+	c := NewCommandReader(`printf 'x\tload\n1\t0.5\n2\t0.7\n'`, 10*time.Millisecond, time.Second, newTabReader)
+	header, err := c.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"x", "load"}; !reflect.DeepEqual(header, want) {
+		t.Errorf("Header() = %q, want %q", header, want)
+	}
+	var got [][]string
+	for i := 0; i < 4; i++ {
+		record, err := c.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, record)
+	}
+	want := [][]string{{"1", "0.5"}, {"2", "0.7"}, {"1", "0.5"}, {"2", "0.7"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Read() = %q, want %q", got, want)
+	}
+	if runs, failures, _ := c.Status(); runs != 2 || failures != 0 {
+		t.Errorf("Status() = %d, %d, want 2 runs without failures", runs, failures)
+	}
+}
+
+func TestCommandReaderErrors(t *testing.T) {
+	for _, tc := range []struct {
+		command string
+		err     string
+	}{
+		{"echo oops >&2; exit 3", "exec: exit status 3: oops"},
+		{"true", "exec: no output"},
+		{`printf 'x\tload\n'`, "exec: no records in output"},
+	} {
+		c := NewCommandReader(tc.command, time.Second, time.Second, newTabReader)
+		_, err := c.Header()
+		if err == nil || err.Error() != tc.err {
+			t.Errorf("%q: Header() error = %v, want %q", tc.command, err, tc.err)
+		}
+		if runs, failures, lastErr := c.Status(); runs != 1 || failures != 1 || lastErr != err {
+			t.Errorf("%q: Status() = %d, %d, %v, want 1 failed run", tc.command, runs, failures, lastErr)
+		}
+	}
+}
+
+// TestCommandReaderTimeout checks that a run is stopped at the timeout, also
+// when the commands started by the shell hold on to its output.
+func TestCommandReaderTimeout(t *testing.T) {
+	for _, command := range []string{"sleep 3", "sleep 3 | cat", "sleep 3; echo done"} {
+		c := NewCommandReader(command, time.Second, 500*time.Millisecond, newTabReader)
+		start := time.Now()
+		_, err := c.Header()
+		elapsed := time.Since(start)
+		if err == nil || !strings.Contains(err.Error(), "timed out after 500ms") {
+			t.Errorf("%q: Header() error = %v, want a timeout", command, err)
+		}
+		if elapsed > 1500*time.Millisecond {
+			t.Errorf("%q: timed out run took %s", command, elapsed)
+		}
+	}
+}
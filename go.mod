@@ -1,6 +1,6 @@
 module github.com/keithknott26/datadash
 
-go 1.19
+go 1.20
 
 require (
 	github.com/andrew-d/go-termutil v0.0.0-20150726205930-009166a695a2
@@ -12,6 +12,7 @@ require (
 require (
 	github.com/alecthomas/template v0.0.0-20190718012654-fb15b899a751 // indirect
 	github.com/alecthomas/units v0.0.0-20211218093645-b94a6e3cc137 // indirect
+	github.com/kylelemons/godebug v1.1.0 // indirect
 	github.com/mattn/go-runewidth v0.0.14 // indirect
 	github.com/nsf/termbox-go v1.1.1 // indirect
 	github.com/rivo/uniseg v0.4.4 // indirect
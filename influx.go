@@ -0,0 +1,248 @@
+package datadash
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// InfluxPoint is one line of the InfluxDB line protocol:
+//
+//	measurement,tag=value field=1.2,other=3i 1556813561098000000
+type InfluxPoint struct {
+	Measurement string
+	Tags        []KeyValue
+	Fields      []InfluxField
+	Time        time.Time
+	HasTime     bool
+}
+
+// InfluxField is a numeric field of an InfluxPoint. String fields are
+// dropped, booleans are converted to 0 and 1.
+type InfluxField struct {
+	Key   string
+	Value float64
+}
+
+// SeriesName returns the name of the series holding the given field, built
+// from the measurement, the field key and the tags: cpu.usage_idle{host=a}.
+func (p *InfluxPoint) SeriesName(field string) string {
+	name := p.Measurement + "." + field
+	if len(p.Tags) == 0 {
+		return name
+	}
+	tags := make([]string, len(p.Tags))
+	for i, t := range p.Tags {
+		tags[i] = t.Key + "=" + t.Value
+	}
+	return name + "{" + strings.Join(tags, ",") + "}"
+}
+
+// ParseInfluxLine parses a single line of the InfluxDB line protocol.
+func ParseInfluxLine(line string) (*InfluxPoint, error) {
+	key, rest := splitUnescaped(line, ' ')
+	fieldSet, ts := splitUnescaped(strings.TrimLeft(rest, " "), ' ')
+	if key == "" || fieldSet == "" {
+		return nil, fmt.Errorf("influx: invalid line %q", line)
+	}
+	p := &InfluxPoint{}
+	parts := splitAllUnescaped(key, ',')
+	p.Measurement = unescape(parts[0])
+	for _, tag := range parts[1:] {
+		k, v := splitUnescaped(tag, '=')
+		p.Tags = append(p.Tags, KeyValue{Key: unescape(k), Value: unescape(v)})
+	}
+	sort.Slice(p.Tags, func(i, j int) bool { return p.Tags[i].Key < p.Tags[j].Key })
+
+	for _, field := range splitAllUnescaped(fieldSet, ',') {
+		k, v := splitUnescaped(field, '=')
+		if k == "" || v == "" {
+			return nil, fmt.Errorf("influx: invalid field %q in line %q", field, line)
+		}
+		var value float64
+		switch {
+		case strings.HasPrefix(v, `"`):
+			continue
+		case v == "t" || v == "T" || strings.EqualFold(v, "true"):
+			value = 1
+		case v == "f" || v == "F" || strings.EqualFold(v, "false"):
+			value = 0
+		default:
+			var err error
+			value, err = strconv.ParseFloat(strings.TrimRight(v, "iu"), 64)
+			if err != nil {
+				return nil, fmt.Errorf("influx: invalid value %q in line %q", v, line)
+			}
+		}
+		p.Fields = append(p.Fields, InfluxField{Key: unescape(k), Value: value})
+	}
+
+	if ts = strings.TrimSpace(ts); ts != "" {
+		ns, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("influx: invalid timestamp %q in line %q", ts, line)
+		}
+		p.Time, p.HasTime = time.Unix(0, ns), true
+	}
+	return p, nil
+}
+
+// splitUnescaped splits s at the first sep which is neither escaped with a
+// backslash nor inside double quotes.
+func splitUnescaped(s string, sep byte) (string, string) {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			return s[:i], s[i+1:]
+		}
+	}
+	return s, ""
+}
+
+func splitAllUnescaped(s string, sep byte) []string {
+	var parts []string
+	for s != "" {
+		var part string
+		part, s = splitUnescaped(s, sep)
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// InfluxReader reads records from InfluxDB line protocol. Every series (one
+// per measurement, tag set and field) is a column, columns are added as new
+// series appear in the input, so records grow longer over time. Fields of
+// series not present on a line are returned empty. The X-Axis label is the
+// time of the point in RFC 3339 format with nanoseconds.
+type InfluxReader struct {
+	scanner *bufio.Scanner
+	pending *InfluxPoint
+	skipped int64
+
+	mu      sync.Mutex
+	columns map[string]int
+	names   []string
+}
+
+// NewInfluxReader returns an InfluxReader reading lines from r.
+func NewInfluxReader(r io.Reader) *InfluxReader {
+	ir := &InfluxReader{
+		scanner: bufio.NewScanner(r),
+		columns: map[string]int{},
+	}
+	ir.scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	return ir
+}
+
+// Header reads the first valid line and returns the label column followed by
+// its series. That line is returned by the next call to Read.
+func (r *InfluxReader) Header() ([]string, error) {
+	p, err := r.next()
+	if err != nil {
+		return nil, err
+	}
+	r.register(p)
+	r.pending = p
+	return append([]string{LabelColumn}, r.Columns()...), nil
+}
+
+// Columns returns the names of the series seen so far. It is safe to call
+// concurrently with Read.
+func (r *InfluxReader) Columns() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.names...)
+}
+
+// Read returns the next point as a record. It returns io.EOF once the input
+// is exhausted.
+func (r *InfluxReader) Read() ([]string, error) {
+	p := r.pending
+	r.pending = nil
+	if p == nil {
+		var err error
+		if p, err = r.next(); err != nil {
+			return nil, err
+		}
+	}
+	r.register(p)
+
+	r.mu.Lock()
+	record := make([]string, len(r.names)+1)
+	for _, f := range p.Fields {
+		record[r.columns[p.SeriesName(f.Key)]+1] = strconv.FormatFloat(f.Value, 'f', -1, 64)
+	}
+	r.mu.Unlock()
+
+	ts := time.Now()
+	if p.HasTime {
+		ts = p.Time
+	}
+	record[0] = ts.Format(time.RFC3339Nano)
+	return record, nil
+}
+
+// Skipped returns the number of lines which could not be parsed. It is safe
+// to call concurrently with Read.
+func (r *InfluxReader) Skipped() int64 {
+	return atomic.LoadInt64(&r.skipped)
+}
+
+// register adds the series of p which haven't been seen before.
+func (r *InfluxReader) register(p *InfluxPoint) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, f := range p.Fields {
+		name := p.SeriesName(f.Key)
+		if _, ok := r.columns[name]; !ok {
+			r.columns[name] = len(r.names)
+			r.names = append(r.names, name)
+		}
+	}
+}
+
+// next returns the next line holding at least one numeric field, skipping
+// blank lines, comments and lines which don't parse.
+func (r *InfluxReader) next() (*InfluxPoint, error) {
+	for r.scanner.Scan() {
+		line := strings.TrimSpace(r.scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p, err := ParseInfluxLine(line)
+		if err != nil || len(p.Fields) == 0 {
+			atomic.AddInt64(&r.skipped, 1)
+			continue
+		}
+		return p, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
@@ -0,0 +1,112 @@
+package datadash
+
+import (
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseInfluxLine(t *testing.T) {
+	for _, tc := range []struct {
+		line   string
+		series []string
+		values []float64
+		time   int64
+	}{
+		{
+			line:   "cpu,region=us,host=a usage_idle=98.5,usage_user=1.5 1556813561098000000",
+			series: []string{"cpu.usage_idle{host=a,region=us}", "cpu.usage_user{host=a,region=us}"},
+			values: []float64{98.5, 1.5},
+			time:   1556813561098000000,
+		},
+		{
+			line:   `my\ cpu,host=web\,1,ta\=g=v\ w us\=er=2`,
+			series: []string{"my cpu.us=er{host=web,1,ta=g=v w}"},
+			values: []float64{2},
+		},
+		{
+			line:   `log msg="hello, world = \"x\"",n=2i,m=3u 10`,
+			series: []string{"log.n", "log.m"},
+			values: []float64{2, 3},
+			time:   10,
+		},
+		{
+			line:   "flags a=t,b=T,c=true,d=f,e=F,g=FALSE,h=-1.5e3",
+			series: []string{"flags.a", "flags.b", "flags.c", "flags.d", "flags.e", "flags.g", "flags.h"},
+			values: []float64{1, 1, 1, 0, 0, 0, -1500},
+		},
+		{
+			line: `log msg="only a string"`,
+		},
+	} {
+		p, err := ParseInfluxLine(tc.line)
+		if err != nil {
+			t.Errorf("ParseInfluxLine(%q): %v", tc.line, err)
+			continue
+		}
+		var series []string
+		var values []float64
+		for _, f := range p.Fields {
+			series = append(series, p.SeriesName(f.Key))
+			values = append(values, f.Value)
+		}
+		if !reflect.DeepEqual(series, tc.series) || !reflect.DeepEqual(values, tc.values) {
+			t.Errorf("ParseInfluxLine(%q) = %q %v, want %q %v", tc.line, series, values, tc.series, tc.values)
+		}
+		if p.HasTime != (tc.time != 0) || tc.time != 0 && p.Time.UnixNano() != tc.time {
+			t.Errorf("ParseInfluxLine(%q) time = %v %t, want %d", tc.line, p.Time, p.HasTime, tc.time)
+		}
+	}
+	for _, line := range []string{"cpu", "cpu usage=", "cpu =1", "cpu usage=abc", "cpu usage=1 soon", "cpu usage=1x"} {
+		if _, err := ParseInfluxLine(line); err == nil {
+			t.Errorf("ParseInfluxLine(%q) succeeded", line)
+		}
+	}
+}
+
+// TestInfluxReader reads series appearing on the fly, labeled with the full
+// precision of their timestamps.
+func TestInfluxReader(t *testing.T) {
+	start := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC).UnixNano()
+	lines := strings.Join([]string{
+		"cpu,host=a usage=10 " + strconv.FormatInt(start, 10),
+		"cpu,host=b usage=20 " + strconv.FormatInt(start, 10),
+		"# comment",
+		"",
+		`mem used=30i,msg="x" ` + strconv.FormatInt(start+500e6, 10),
+		"bad line",
+		"cpu,host=a usage=11 " + strconv.FormatInt(start+1e9, 10),
+	}, "\n")
+	r := NewInfluxReader(strings.NewReader(lines))
+	header, err := r.Header()
+	if err != nil || !reflect.DeepEqual(header, []string{"x", "cpu.usage{host=a}"}) {
+		t.Fatalf("Header() = %q, %v", header, err)
+	}
+	label := func(ns int64) string { return time.Unix(0, ns).Format(time.RFC3339Nano) }
+	for _, want := range [][]string{
+		{label(start), "10"},
+		{label(start), "", "20"},
+		{label(start + 500e6), "", "", "30"},
+		{label(start + 1e9), "11", "", ""},
+	} {
+		rec, err := r.Read()
+		if err != nil || !reflect.DeepEqual(rec, want) {
+			t.Fatalf("Read() = %q, %v, want %q", rec, err, want)
+		}
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("Read() at the end = %v, want EOF", err)
+	}
+	if want := []string{"cpu.usage{host=a}", "cpu.usage{host=b}", "mem.used"}; !reflect.DeepEqual(r.Columns(), want) {
+		t.Errorf("Columns() = %q, want %q", r.Columns(), want)
+	}
+	if r.Skipped() != 1 {
+		t.Errorf("Skipped() = %d, want 1", r.Skipped())
+	}
+	if ts, ok := ParseTimestamp(label(start + 500e6)); !ok || ts.UnixNano() != start+500e6 {
+		t.Errorf("ParseTimestamp() of a label = %v, %t, want the time of the point", ts, ok)
+	}
+}
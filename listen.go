@@ -0,0 +1,237 @@
+package datadash
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// SocketServer accepts any number of concurrent connections on a TCP or Unix
+// socket and merges the records sent by the clients into one stream. Every
+// connection is read with its own ColumnReader, columns with the same name
+// are shared unless Tag is set, in which case they are prefixed with the
+// connection's remote address (or connN for Unix sockets).
+type SocketServer struct {
+	Tag       bool
+	listener  net.Listener
+	newReader func(io.Reader) (ColumnReader, error)
+	records   chan []string
+	pending   []string
+
+	columns *columnSet
+	mu      sync.Mutex
+	conns   int
+	active  int
+	lastErr error
+}
+
+// ListenSocket listens on a tcp://host:port or unix:///path address. Every
+// accepted connection is read with the reader returned by newReader.
+func ListenSocket(address string, tag bool, newReader func(io.Reader) (ColumnReader, error)) (*SocketServer, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("listen %q: %v", address, err)
+	}
+	var l net.Listener
+	switch u.Scheme {
+	case "tcp", "tcp4", "tcp6":
+		l, err = net.Listen(u.Scheme, u.Host)
+	case "unix":
+		path := u.Path
+		if u.Host != "" {
+			path = u.Host + u.Path
+		}
+		if err := removeStaleSocket(path); err != nil {
+			return nil, fmt.Errorf("listen %q: %v", address, err)
+		}
+		l, err = net.Listen("unix", path)
+	default:
+		return nil, fmt.Errorf("listen %q: scheme must be tcp:// or unix://", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := &SocketServer{
+		Tag:       tag,
+		listener:  l,
+		newReader: newReader,
+		records:   make(chan []string, 100),
+		columns:   newColumnSet(),
+	}
+	go s.accept()
+	return s, nil
+}
+
+// removeStaleSocket removes a Unix socket left behind by a previous run. A
+// socket still accepting connections belongs to a running server and is kept,
+// so that listening on it fails.
+func removeStaleSocket(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil || fi.Mode()&os.ModeSocket == 0 {
+		return nil
+	}
+	conn, err := net.Dial("unix", path)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use", path)
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return nil
+	}
+	return os.Remove(path)
+}
+
+// Addr returns the address the server listens on.
+func (s *SocketServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Close stops accepting connections.
+func (s *SocketServer) Close() error {
+	return s.listener.Close()
+}
+
+// Header waits for the first record and returns the label column followed by
+// the columns seen so far. That record is returned by the next call to Read.
+func (s *SocketServer) Header() ([]string, error) {
+	record, err := s.Read()
+	if err != nil {
+		return nil, err
+	}
+	s.pending = record
+	return append([]string{LabelColumn}, s.Columns()...), nil
+}
+
+// Read returns the next record received from any of the clients.
+func (s *SocketServer) Read() ([]string, error) {
+	if record := s.pending; record != nil {
+		s.pending = nil
+		return record, nil
+	}
+	record, ok := <-s.records
+	if !ok {
+		return nil, io.EOF
+	}
+	return record, nil
+}
+
+// Columns returns the names of the columns seen so far. It is safe to call
+// concurrently with Read.
+func (s *SocketServer) Columns() []string {
+	return s.columns.Names()
+}
+
+// Status returns the number of connections accepted, of currently open
+// connections and the last error reading from a client.
+func (s *SocketServer) Status() (conns, active int, lastErr error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns, s.active, s.lastErr
+}
+
+func (s *SocketServer) accept() {
+	defer close(s.records)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns++
+		s.active++
+		tag := conn.RemoteAddr().String()
+		if tag == "" || tag == "@" {
+			tag = fmt.Sprintf("conn%d", s.conns)
+		}
+		s.mu.Unlock()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serve(conn, tag)
+		}()
+	}
+}
+
+func (s *SocketServer) serve(conn net.Conn, tag string) {
+	defer conn.Close()
+	err := s.read(conn, tag)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active--
+	if err != nil && err != io.EOF {
+		s.lastErr = fmt.Errorf("%s: %v", tag, err)
+	}
+}
+
+func (s *SocketServer) read(conn net.Conn, tag string) error {
+	r, err := s.newReader(conn)
+	if err != nil {
+		return err
+	}
+	for {
+		record, err := r.Read()
+		if err != nil {
+			return err
+		}
+		if len(record) == 0 {
+			continue
+		}
+		prefix := ""
+		if s.Tag {
+			prefix = tag + "/"
+		}
+		s.records <- s.columns.Merge(prefix, r.Columns(), record)
+	}
+}
+
+// columnSet assigns an index to every column name of records merged from
+// several readers. It is safe for concurrent use.
+type columnSet struct {
+	mu      sync.Mutex
+	indexes map[string]int
+	names   []string
+}
+
+func newColumnSet() *columnSet {
+	return &columnSet{indexes: map[string]int{}}
+}
+
+// Names returns the column names in order of appearance.
+func (c *columnSet) Names() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.names...)
+}
+
+// Merge maps a record with the given columns onto all columns of the set,
+// adding the unknown ones. Column names are prefixed with prefix.
+func (c *columnSet) Merge(prefix string, columns []string, record []string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	indexes := make([]int, len(columns))
+	for i, name := range columns {
+		name = prefix + name
+		col, ok := c.indexes[name]
+		if !ok {
+			col = len(c.names)
+			c.indexes[name] = col
+			c.names = append(c.names, name)
+		}
+		indexes[i] = col
+	}
+	merged := make([]string, len(c.names)+1)
+	merged[0] = record[0]
+	for i, v := range record[1:] {
+		if i < len(indexes) {
+			merged[indexes[i]+1] = v
+		}
+	}
+	return merged
+}
@@ -0,0 +1,59 @@
+package datadash
+
+import (
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newSocketReader(r io.Reader) (ColumnReader, error) {
+	return NewCSVReader(r, '\t')
+}
+
+// TestListenSocketStale listens on the path of a socket left behind by a
+// server which exited without removing it.
+func TestListenSocketStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dd.sock")
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.SetUnlinkOnClose(false)
+	l.Close()
+
+	s, err := ListenSocket("unix://"+path, false, newSocketReader)
+	if err != nil {
+		t.Fatalf("ListenSocket() on a stale socket: %v", err)
+	}
+	defer s.Close()
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(conn, "x\tload\n1\t0.5\n")
+	conn.Close()
+	header, err := s.Header()
+	if err != nil || strings.Join(header, ",") != LabelColumn+",load" {
+		t.Errorf("Header() = %q, %v, want the load column", header, err)
+	}
+}
+
+// TestListenSocketInUse keeps the socket of a running server.
+func TestListenSocketInUse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dd.sock")
+	s, err := ListenSocket("unix://"+path, false, newSocketReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := ListenSocket("unix://"+path, false, newSocketReader); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Errorf("second ListenSocket() error = %v, want in use", err)
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("the socket of the running server was removed: %v", err)
+	}
+	conn.Close()
+}
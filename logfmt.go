@@ -0,0 +1,167 @@
+package datadash
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// KeyValue is a single key=value pair of a logfmt line.
+type KeyValue struct {
+	Key   string
+	Value string
+}
+
+// ParseLogfmt splits a logfmt line (key=value key2="quoted value") into its
+// pairs. Keys without a value are returned with the value "true".
+func ParseLogfmt(line string) []KeyValue {
+	var pairs []KeyValue
+	i := 0
+	for i < len(line) {
+		for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+			i++
+		}
+		start := i
+		for i < len(line) && line[i] != '=' && line[i] != ' ' && line[i] != '\t' {
+			i++
+		}
+		key := line[start:i]
+		if key == "" {
+			i++
+			continue
+		}
+		if i >= len(line) || line[i] != '=' {
+			pairs = append(pairs, KeyValue{Key: key, Value: "true"})
+			continue
+		}
+		i++ // skip '='
+		var value string
+		if i < len(line) && line[i] == '"' {
+			var b strings.Builder
+			i++
+			for i < len(line) && line[i] != '"' {
+				if line[i] == '\\' && i+1 < len(line) {
+					i++
+				}
+				b.WriteByte(line[i])
+				i++
+			}
+			i++ // skip closing quote
+			value = b.String()
+		} else {
+			start = i
+			for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+				i++
+			}
+			value = line[start:i]
+		}
+		pairs = append(pairs, KeyValue{Key: key, Value: value})
+	}
+	return pairs
+}
+
+// LogfmtReader reads records from logfmt lines. Every column is the value of
+// one key, the X-Axis label is the value of LabelKey.
+type LogfmtReader struct {
+	LabelKey string
+	keys     []string
+	scanner  *bufio.Scanner
+	pending  []KeyValue
+	skipped  int64
+}
+
+// NewLogfmtReader returns a LogfmtReader reading lines from r. Only the given
+// keys are plotted, if there are none the numeric keys of the first line are
+// used.
+func NewLogfmtReader(r io.Reader, labelKey string, keys []string) *LogfmtReader {
+	lr := &LogfmtReader{
+		LabelKey: labelKey,
+		keys:     keys,
+		scanner:  bufio.NewScanner(r),
+	}
+	lr.scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	return lr
+}
+
+// Header returns the column names: the label key followed by the plotted
+// keys. When no keys were given it reads ahead until a line with numeric keys
+// is found, that line is returned by the next call to Read.
+func (r *LogfmtReader) Header() ([]string, error) {
+	for len(r.keys) == 0 {
+		pairs, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		for _, kv := range pairs {
+			if _, err := strconv.ParseFloat(kv.Value, 64); err == nil && kv.Key != r.LabelKey {
+				r.keys = append(r.keys, kv.Key)
+			}
+		}
+		if len(r.keys) == 0 {
+			atomic.AddInt64(&r.skipped, 1)
+			continue
+		}
+		r.pending = pairs
+	}
+	label := r.LabelKey
+	if label == "" {
+		label = LabelColumn
+	}
+	return append([]string{label}, r.keys...), nil
+}
+
+// Columns returns the plotted keys, they are known once Header returned.
+func (r *LogfmtReader) Columns() []string {
+	return r.keys
+}
+
+// Read returns the next line holding at least one of the plotted keys as a
+// record, missing keys are returned as empty fields. It returns io.EOF once
+// the input is exhausted.
+func (r *LogfmtReader) Read() ([]string, error) {
+	for {
+		pairs := r.pending
+		r.pending = nil
+		if pairs == nil {
+			var err error
+			if pairs, err = r.next(); err != nil {
+				return nil, err
+			}
+		}
+		record := make([]string, len(r.keys)+1)
+		found := false
+		for _, kv := range pairs {
+			if kv.Key == r.LabelKey {
+				record[0] = kv.Value
+			}
+			for i, k := range r.keys {
+				if kv.Key == k {
+					record[i+1] = kv.Value
+					found = true
+				}
+			}
+		}
+		if found {
+			return record, nil
+		}
+		atomic.AddInt64(&r.skipped, 1)
+	}
+}
+
+// Skipped returns the number of lines which held none of the plotted keys. It
+// is safe to call concurrently with Read.
+func (r *LogfmtReader) Skipped() int64 {
+	return atomic.LoadInt64(&r.skipped)
+}
+
+func (r *LogfmtReader) next() ([]KeyValue, error) {
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	return ParseLogfmt(r.scanner.Text()), nil
+}
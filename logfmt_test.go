@@ -0,0 +1,105 @@
+package datadash
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLogfmt(t *testing.T) {
+	for _, tc := range []struct {
+		line string
+		want []KeyValue
+	}{
+		{"a=1 b=2", []KeyValue{{"a", "1"}, {"b", "2"}}},
+		{"  a=1\tb=x  ", []KeyValue{{"a", "1"}, {"b", "x"}}},
+		{`msg="hello world" n=3`, []KeyValue{{"msg", "hello world"}, {"n", "3"}}},
+		{`msg="say \"hi\" \\ bye" n=3`, []KeyValue{{"msg", `say "hi" \ bye`}, {"n", "3"}}},
+		{`msg="" n=`, []KeyValue{{"msg", ""}, {"n", ""}}},
+		{"debug n=3 cached", []KeyValue{{"debug", "true"}, {"n", "3"}, {"cached", "true"}}},
+		{`msg="unterminated`, []KeyValue{{"msg", "unterminated"}}},
+		{"=1 a=2", []KeyValue{{"1", "true"}, {"a", "2"}}},
+		{"", nil},
+	} {
+		if got := ParseLogfmt(tc.line); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("ParseLogfmt(%q) = %q, want %q", tc.line, got, tc.want)
+		}
+	}
+}
+
+func TestLogfmtReader(t *testing.T) {
+	lines := `level=info msg="starting up"
+ts=10:00:01 level=info latency=12 size=300 path=/a
+ts=10:00:02 level=warn msg="slow request" latency=340
+ts=10:00:03 level=info size=200 retries=1
+ts=10:00:04 level=info retries=2
+`
+	for _, tc := range []struct {
+		name     string
+		labelKey string
+		keys     []string
+		header   []string
+		records  [][]string
+		skipped  int64
+	}{
+		{
+			name:    "numeric keys of the first line",
+			header:  []string{"x", "latency", "size"},
+			records: [][]string{{"", "12", "300"}, {"", "340", ""}, {"", "", "200"}},
+			skipped: 2,
+		},
+		{
+			name:     "label key",
+			labelKey: "ts",
+			header:   []string{"ts", "latency", "size"},
+			records:  [][]string{{"10:00:01", "12", "300"}, {"10:00:02", "340", ""}, {"10:00:03", "", "200"}},
+			skipped:  2,
+		},
+		{
+			name:     "allowed keys",
+			labelKey: "ts",
+			keys:     []string{"retries", "latency"},
+			header:   []string{"ts", "retries", "latency"},
+			records:  [][]string{{"10:00:01", "", "12"}, {"10:00:02", "", "340"}, {"10:00:03", "1", ""}, {"10:00:04", "2", ""}},
+			skipped:  1,
+		},
+	} {
+		r := NewLogfmtReader(strings.NewReader(lines), tc.labelKey, tc.keys)
+		header, err := r.Header()
+		if err != nil || !reflect.DeepEqual(header, tc.header) {
+			t.Errorf("%s: Header() = %q, %v, want %q", tc.name, header, err, tc.header)
+			continue
+		}
+		if !reflect.DeepEqual(r.Columns(), tc.header[1:]) {
+			t.Errorf("%s: Columns() = %q", tc.name, r.Columns())
+		}
+		var records [][]string
+		for {
+			rec, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: Read(): %v", tc.name, err)
+			}
+			records = append(records, rec)
+		}
+		if !reflect.DeepEqual(records, tc.records) {
+			t.Errorf("%s: records = %q, want %q", tc.name, records, tc.records)
+		}
+		if got := r.Skipped(); got != tc.skipped {
+			t.Errorf("%s: Skipped() = %d, want %d", tc.name, got, tc.skipped)
+		}
+	}
+}
+
+func TestLogfmtReaderNoNumbers(t *testing.T) {
+	r := NewLogfmtReader(strings.NewReader("msg=a\nmsg=b level=info\n"), "", nil)
+	if _, err := r.Header(); err != io.EOF {
+		t.Errorf("Header() of lines without numbers = %v, want EOF", err)
+	}
+	if r.Skipped() != 2 {
+		t.Errorf("Skipped() = %d, want 2", r.Skipped())
+	}
+}
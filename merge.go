@@ -0,0 +1,130 @@
+package datadash
+
+import (
+	"io"
+	"time"
+)
+
+// MergeReader reads several inputs as one. The columns of every input are
+// prefixed with its name ("host1.tsv/latency"). Records are either taken from
+// the inputs in turn, or, when ByTime is set, in the order of their X-Axis
+// label timestamps with records of the same time merged into one record.
+type MergeReader struct {
+	ByTime   bool
+	prefixes []string
+	readers  []ColumnReader
+	heads    [][]string
+	done     []bool
+	columns  *columnSet
+	next     int
+}
+
+// NewMergeReader returns a MergeReader reading the given inputs, the columns
+// of readers[i] are prefixed with names[i].
+func NewMergeReader(names []string, readers []ColumnReader, byTime bool) *MergeReader {
+	m := &MergeReader{
+		ByTime:  byTime,
+		readers: readers,
+		heads:   make([][]string, len(readers)),
+		done:    make([]bool, len(readers)),
+		columns: newColumnSet(),
+	}
+	for i, r := range readers {
+		m.prefixes = append(m.prefixes, names[i]+"/")
+		//register the known columns in input order
+		m.columns.Merge(m.prefixes[i], r.Columns(), []string{""})
+	}
+	return m
+}
+
+// Header returns the label column followed by the prefixed columns of all
+// inputs.
+func (m *MergeReader) Header() []string {
+	return append([]string{LabelColumn}, m.Columns()...)
+}
+
+// Columns returns the names of the columns seen so far.
+func (m *MergeReader) Columns() []string {
+	return m.columns.Names()
+}
+
+// Read returns the next record. It returns io.EOF once all inputs are
+// exhausted.
+func (m *MergeReader) Read() ([]string, error) {
+	if m.ByTime {
+		return m.readByTime()
+	}
+	for range m.readers {
+		i := m.next
+		m.next = (m.next + 1) % len(m.readers)
+		record, err := m.head(i)
+		if err != nil {
+			return nil, err
+		}
+		if record != nil {
+			m.heads[i] = nil
+			return m.columns.Merge(m.prefixes[i], m.readers[i].Columns(), record), nil
+		}
+	}
+	return nil, io.EOF
+}
+
+// readByTime returns the merged records of the earliest timestamp. Labels
+// which aren't timestamps sort first.
+func (m *MergeReader) readByTime() ([]string, error) {
+	var earliest time.Time
+	var earliestTimed bool
+	var selected []int
+	for i := range m.readers {
+		record, err := m.head(i)
+		if err != nil {
+			return nil, err
+		}
+		if record == nil {
+			continue
+		}
+		ts, timed := ParseTimestamp(record[0])
+		switch {
+		case selected == nil || earliestTimed && !timed || timed == earliestTimed && ts.Before(earliest):
+			earliest, earliestTimed, selected = ts, timed, []int{i}
+		case timed == earliestTimed && ts.Equal(earliest):
+			selected = append(selected, i)
+		}
+	}
+	if selected == nil {
+		return nil, io.EOF
+	}
+	var merged []string
+	for _, i := range selected {
+		record := m.columns.Merge(m.prefixes[i], m.readers[i].Columns(), m.heads[i])
+		m.heads[i] = nil
+		for len(merged) < len(record) {
+			merged = append(merged, "")
+		}
+		for j, v := range record {
+			if merged[j] == "" {
+				merged[j] = v
+			}
+		}
+	}
+	return merged, nil
+}
+
+// head returns the next unread record of input i, or nil once it is
+// exhausted.
+func (m *MergeReader) head(i int) ([]string, error) {
+	for m.heads[i] == nil && !m.done[i] {
+		record, err := m.readers[i].Read()
+		if err == io.EOF {
+			m.done[i] = true
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) > 0 {
+			m.heads[i] = record
+		}
+	}
+	return m.heads[i], nil
+}
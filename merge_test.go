@@ -0,0 +1,84 @@
+package datadash
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newMergeReader(t *testing.T, byTime bool, inputs ...string) *MergeReader {
+	t.Helper()
+	var names []string
+	var readers []ColumnReader
+	for i, input := range inputs {
+		r, err := NewCSVReader(strings.NewReader(input), '\t')
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, string(rune('a'+i))+".tsv")
+		readers = append(readers, r)
+	}
+	return NewMergeReader(names, readers, byTime)
+}
+
+func readAll(t *testing.T, r ColumnReader) [][]string {
+	t.Helper()
+	var records [][]string
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return records
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+}
+
+func TestMergeReaderInterleave(t *testing.T) {
+	m := newMergeReader(t, false,
+		"x\tlatency\n1\t10\n2\t11\n3\t12\n",
+		"x\tlatency\terrors\n1\t20\t0\n",
+		"x\tload\n1\t0.5\n2\t0.6\n",
+	)
+	want := []string{"x", "a.tsv/latency", "b.tsv/latency", "b.tsv/errors", "c.tsv/load"}
+	if !reflect.DeepEqual(m.Header(), want) {
+		t.Errorf("Header() = %q, want %q", m.Header(), want)
+	}
+	//one record of each input in turn, skipping the exhausted ones, with a
+	//field for every column
+	records := [][]string{
+		{"1", "10", "", "", ""},
+		{"1", "", "20", "0", ""},
+		{"1", "", "", "", "0.5"},
+		{"2", "11", "", "", ""},
+		{"2", "", "", "", "0.6"},
+		{"3", "12", "", "", ""},
+	}
+	if got := readAll(t, m); !reflect.DeepEqual(got, records) {
+		t.Errorf("Read() = %q, want %q", got, records)
+	}
+}
+
+func TestMergeReaderByTime(t *testing.T) {
+	m := newMergeReader(t, true,
+		"x\tlatency\n10:00:01\t10\n10:00:03\t11\n10:00:04\t12\n",
+		"x\tlatency\n10:00:00\t20\n10:00:03\t21\n10:00:05\t22\n",
+		"x\tload\nstart\t0.1\n10:00:04\t0.5\n",
+	)
+	//labels which aren't timestamps sort first, records of the same time are
+	//merged with the label of the first input
+	records := [][]string{
+		{"start", "", "", "0.1"},
+		{"10:00:00", "", "20", ""},
+		{"10:00:01", "10", "", ""},
+		{"10:00:03", "11", "21", ""},
+		{"10:00:04", "12", "", "0.5"},
+		{"10:00:05", "", "22", ""},
+	}
+	if got := readAll(t, m); !reflect.DeepEqual(got, records) {
+		t.Errorf("Read() = %q, want %q", got, records)
+	}
+}
@@ -0,0 +1,124 @@
+package datadash
+
+import (
+	"time"
+
+	"github.com/mum4k/termdash/terminal/terminalapi"
+)
+
+// Option configures a Row, a Series or a Dashboard. Options given to New are
+// the defaults of every series of the dashboard.
+type Option func(*config)
+
+type config struct {
+	id             int
+	bufferSize     int
+	graphType      string
+	scroll         bool
+	average        bool
+	averageSeek    int
+	yAxisAdaptive  bool
+	theme          *Theme
+	depth          ColorDepth
+	redrawInterval time.Duration
+	terminal       terminalapi.Terminal
+	clock          Clock
+}
+
+// default settings, matching the defaults of the datadash command
+const (
+	DefaultBufferSize     = 1440
+	DefaultAverageSeek    = 500
+	DefaultRedrawInterval = 10 * time.Millisecond
+)
+
+func newConfig(opts []Option) *config {
+	c := &config{
+		id:             -1,
+		bufferSize:     DefaultBufferSize,
+		graphType:      "line",
+		averageSeek:    DefaultAverageSeek,
+		depth:          -1,
+		redrawInterval: DefaultRedrawInterval,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Line draws a series as a line chart, the default.
+func Line() Option {
+	return func(c *config) { c.graphType = "line" }
+}
+
+// Bar draws a series as a bar chart.
+func Bar() Option {
+	return func(c *config) { c.graphType = "bar" }
+}
+
+// Spark draws a series as a sparkline.
+func Spark() Option {
+	return func(c *config) { c.graphType = "spark" }
+}
+
+// SeriesID sets the ID of a row, which selects its color. A Dashboard numbers
+// its series from 1 unless set.
+func SeriesID(id int) Option {
+	return func(c *config) { c.id = id }
+}
+
+// BufferSize sets the number of values kept for scrolling and the
+// statistics, older values are dropped. Default: DefaultBufferSize.
+func BufferSize(n int) Option {
+	return func(c *config) { c.bufferSize = n }
+}
+
+// Scroll draws only the latest values fitting the graph instead of all.
+func Scroll(on bool) Option {
+	return func(c *config) { c.scroll = on }
+}
+
+// AverageLine draws the average of the last seek values along with the
+// values. A seek of 0 keeps the previous setting (DefaultAverageSeek).
+func AverageLine(on bool, seek int) Option {
+	return func(c *config) {
+		c.average = on
+		if seek > 0 {
+			c.averageSeek = seek
+		}
+	}
+}
+
+// YAxisAdaptive makes the Y axis start at the smallest value instead of zero.
+func YAxisAdaptive(on bool) Option {
+	return func(c *config) { c.yAxisAdaptive = on }
+}
+
+// ColorTheme sets the theme. Default: the DefaultTheme.
+func ColorTheme(t *Theme) Option {
+	return func(c *config) { c.theme = t }
+}
+
+// Colors sets the color depth of the terminal of a Dashboard, the theme is
+// mapped down to it. Default: detected from the environment.
+func Colors(d ColorDepth) Option {
+	return func(c *config) { c.depth = d }
+}
+
+// RedrawInterval sets how often the widgets are redrawn. Default:
+// DefaultRedrawInterval.
+func RedrawInterval(d time.Duration) Option {
+	return func(c *config) { c.redrawInterval = d }
+}
+
+// UseClock times updates and redraws with c instead of RealClock, e.g. a
+// FakeClock in tests.
+func UseClock(c Clock) Option {
+	return func(cfg *config) { cfg.clock = c }
+}
+
+// Terminal draws a Dashboard on t instead of a termbox terminal.
+func Terminal(t terminalapi.Terminal) Option {
+	return func(c *config) { c.terminal = t }
+}
@@ -0,0 +1,204 @@
+package datadash
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// diagnostic columns of JSONPoller
+const (
+	PollLatency = "poll.latency_ms"
+	PollErrors  = "poll.errors"
+)
+
+// JSONPath selects a value of a decoded JSON document with a dotted path
+// such as $.queue.depth or workers[0].busy.
+type JSONPath struct {
+	Path  string
+	steps []interface{}
+}
+
+// ParseJSONPath parses a dotted path, array elements are selected with [n].
+func ParseJSONPath(path string) (*JSONPath, error) {
+	p := &JSONPath{Path: path}
+	rest := strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	for rest != "" {
+		switch {
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("path %q: missing ']'", path)
+			}
+			n, err := strconv.Atoi(rest[1:end])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("path %q: invalid index %q", path, rest[1:end])
+			}
+			p.steps = append(p.steps, n)
+			rest = rest[end+1:]
+			if rest != "" && rest[0] != '.' && rest[0] != '[' {
+				return nil, fmt.Errorf("path %q: missing '.' after ']'", path)
+			}
+			rest = strings.TrimPrefix(rest, ".")
+		default:
+			end := strings.IndexAny(rest, ".[]")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("path %q: empty key", path)
+			}
+			if end < len(rest) && rest[end] == ']' {
+				return nil, fmt.Errorf("path %q: missing '['", path)
+			}
+			p.steps = append(p.steps, rest[:end])
+			rest = strings.TrimPrefix(rest[end:], ".")
+		}
+	}
+	if len(p.steps) == 0 {
+		return nil, fmt.Errorf("path %q: empty path", path)
+	}
+	return p, nil
+}
+
+// Value returns the selected value as a number. Booleans are 0 or 1, strings
+// are parsed, and arrays and objects return their length.
+func (p *JSONPath) Value(doc interface{}) (float64, error) {
+	v := doc
+	for _, step := range p.steps {
+		switch s := step.(type) {
+		case string:
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				return 0, fmt.Errorf("%s: %q is not an object", p.Path, s)
+			}
+			if v, ok = obj[s]; !ok {
+				return 0, fmt.Errorf("%s: no key %q", p.Path, s)
+			}
+		case int:
+			arr, ok := v.([]interface{})
+			if !ok || s >= len(arr) {
+				return 0, fmt.Errorf("%s: no element [%d]", p.Path, s)
+			}
+			v = arr[s]
+		}
+	}
+	switch x := v.(type) {
+	case float64:
+		return x, nil
+	case bool:
+		if x {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(x), 64)
+	case []interface{}:
+		return float64(len(x)), nil
+	case map[string]interface{}:
+		return float64(len(x)), nil
+	}
+	return 0, fmt.Errorf("%s: null", p.Path)
+}
+
+// JSONPoller requests a JSON document every interval and returns one record
+// per request, with a column per path followed by the request latency and
+// the number of errors so far. A failed request or a path missing from the
+// document counts as an error and leaves the path columns empty.
+type JSONPoller struct {
+	URL      string
+	Interval time.Duration
+	Paths    []*JSONPath
+	Client   *http.Client
+	next     time.Time
+
+	mu       sync.Mutex
+	requests int
+	errors   int
+	lastErr  error
+}
+
+// NewJSONPoller returns a JSONPoller requesting url every interval.
+func NewJSONPoller(url string, interval time.Duration, paths []*JSONPath) *JSONPoller {
+	return &JSONPoller{
+		URL:      url,
+		Interval: interval,
+		Paths:    paths,
+		Client:   &http.Client{Timeout: interval},
+	}
+}
+
+// Columns returns the paths followed by the diagnostic columns.
+func (p *JSONPoller) Columns() []string {
+	columns := make([]string, 0, len(p.Paths)+2)
+	for _, path := range p.Paths {
+		columns = append(columns, path.Path)
+	}
+	return append(columns, PollLatency, PollErrors)
+}
+
+// Read waits for the next interval and returns the result of a request.
+func (p *JSONPoller) Read() ([]string, error) {
+	time.Sleep(time.Until(p.next))
+	start := time.Now()
+	p.next = start.Add(p.Interval)
+	doc, err := p.fetch()
+	latency := time.Since(start)
+
+	record := make([]string, len(p.Paths)+3)
+	record[0] = start.Format("15:04:05")
+	if err == nil {
+		for i, path := range p.Paths {
+			v, pathErr := path.Value(doc)
+			if pathErr != nil {
+				err = pathErr
+				continue
+			}
+			record[i+1] = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests++
+	if err != nil {
+		p.errors++
+		p.lastErr = err
+	}
+	record[len(p.Paths)+1] = strconv.FormatFloat(float64(latency)/float64(time.Millisecond), 'f', 2, 64)
+	record[len(p.Paths)+2] = strconv.Itoa(p.errors)
+	return record, nil
+}
+
+// Status returns the number of requests, of errors and the last
+// error. It is safe to call concurrently with Read.
+func (p *JSONPoller) Status() (requests, errors int, lastErr error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.requests, p.errors, p.lastErr
+}
+
+func (p *JSONPoller) fetch() (interface{}, error) {
+	req, err := http.NewRequest(http.MethodGet, p.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("poll %s: %s", p.URL, resp.Status)
+	}
+	var doc interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("poll %s: %v", p.URL, err)
+	}
+	return doc, nil
+}
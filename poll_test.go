@@ -0,0 +1,133 @@
+package datadash
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	for _, tc := range []struct {
+		path  string
+		steps []interface{}
+	}{
+		{"a", []interface{}{"a"}},
+		{"a.b", []interface{}{"a", "b"}},
+		{"$.a.b", []interface{}{"a", "b"}},
+		{"a[0].b", []interface{}{"a", 0, "b"}},
+		{"a[1][2]", []interface{}{"a", 1, 2}},
+		{"[3].x", []interface{}{3, "x"}},
+		{"$[0]", []interface{}{0}},
+	} {
+		p, err := ParseJSONPath(tc.path)
+		if err != nil {
+			t.Errorf("ParseJSONPath(%q): %v", tc.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(p.steps, tc.steps) {
+			t.Errorf("ParseJSONPath(%q) = %v, want %v", tc.path, p.steps, tc.steps)
+		}
+	}
+	for _, path := range []string{"", "$", "a[", "a[0", "a[]", "a[x]", "a[-1]", "a]", "a0].b", "a[0]b", "a..b", ".[0]x"} {
+		if _, err := ParseJSONPath(path); err == nil {
+			t.Errorf("ParseJSONPath(%q) succeeded, want an error", path)
+		}
+	}
+}
+
+func TestJSONPathValue(t *testing.T) {
+	var doc interface{}
+	err := json.Unmarshal([]byte(`{"queue": {"depth": 7, "name": "jobs"}, "ok": true, "load": " 1.5",
+		"workers": [{"busy": false}, {"busy": true}], "none": null}`), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		path string
+		want float64
+	}{
+		{"queue.depth", 7},
+		{"queue", 2},
+		{"ok", 1},
+		{"load", 1.5},
+		{"workers", 2},
+		{"workers[0].busy", 0},
+		{"workers[1].busy", 1},
+	} {
+		p, err := ParseJSONPath(tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, err := p.Value(doc); err != nil || v != tc.want {
+			t.Errorf("Value(%q) = %v, %v, want %v", tc.path, v, err, tc.want)
+		}
+	}
+	for _, path := range []string{"queue.size", "queue.name", "ok.x", "workers[2]", "queue[0]", "none"} {
+		p, err := ParseJSONPath(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, err := p.Value(doc); err == nil {
+			t.Errorf("Value(%q) = %v, want an error", path, v)
+		}
+	}
+}
+
+func TestJSONPoller(t *testing.T) {
+	var requests int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&requests, 1)
+		time.Sleep(20 * time.Millisecond)
+		switch n {
+		case 2:
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+		case 3:
+			fmt.Fprint(w, `{"queue": {}}`)
+		default:
+			fmt.Fprintf(w, `{"queue": {"depth": %d}}`, n)
+		}
+	}))
+	defer srv.Close()
+
+	p := NewJSONPoller(srv.URL, 100*time.Millisecond, []*JSONPath{mustParseJSONPath(t, "queue.depth")})
+	if want := []string{"queue.depth", PollLatency, PollErrors}; !reflect.DeepEqual(p.Columns(), want) {
+		t.Errorf("Columns() = %q, want %q", p.Columns(), want)
+	}
+	//the request failing, the path missing from the document and a success
+	for i, want := range []struct {
+		depth  string
+		errors string
+	}{{"1", "0"}, {"", "1"}, {"", "2"}, {"4", "2"}} {
+		record, err := p.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(record) != 4 || record[1] != want.depth || record[3] != want.errors {
+			t.Errorf("Read() #%d = %q, want depth %q and %s errors", i+1, record, want.depth, want.errors)
+			continue
+		}
+		latency, err := strconv.ParseFloat(record[2], 64)
+		if err != nil || latency < 20 || latency > 1000 {
+			t.Errorf("Read() #%d latency = %q ms, want at least 20", i+1, record[2])
+		}
+	}
+	n, errors, lastErr := p.Status()
+	if n != 4 || errors != 2 || lastErr == nil {
+		t.Errorf("Status() = %d, %d, %v, want 4 requests and 2 errors", n, errors, lastErr)
+	}
+}
+
+func mustParseJSONPath(t *testing.T, path string) *JSONPath {
+	t.Helper()
+	p, err := ParseJSONPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
@@ -0,0 +1,423 @@
+package datadash
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PromSample is a single sample of the Prometheus text exposition format.
+type PromSample struct {
+	Name   string
+	Labels []KeyValue
+	Value  float64
+}
+
+// SeriesName returns the sample's name followed by its labels:
+// http_requests_total{code="200",method="get"}.
+func (s *PromSample) SeriesName() string {
+	if len(s.Labels) == 0 {
+		return s.Name
+	}
+	labels := make([]string, len(s.Labels))
+	for i, l := range s.Labels {
+		labels[i] = l.Key + "=" + strconv.Quote(l.Value)
+	}
+	return s.Name + "{" + strings.Join(labels, ",") + "}"
+}
+
+// Label returns the value of the named label, or "" if it isn't set.
+func (s *PromSample) Label(name string) string {
+	for _, l := range s.Labels {
+		if l.Key == name {
+			return l.Value
+		}
+	}
+	return ""
+}
+
+// ParsePromText parses the Prometheus text exposition format. It returns the
+// samples, the metric types declared by # TYPE lines and the number of
+// malformed lines, which are skipped.
+func ParsePromText(r io.Reader) ([]PromSample, map[string]string, int, error) {
+	var samples []PromSample
+	types := map[string]string{}
+	skipped := 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			f := strings.Fields(line)
+			if len(f) == 4 && f[1] == "TYPE" {
+				types[f[2]] = f[3]
+			}
+			continue
+		}
+		s, err := parsePromSample(line)
+		if err != nil {
+			skipped++
+			continue
+		}
+		samples = append(samples, s)
+	}
+	return samples, types, skipped, scanner.Err()
+}
+
+func parsePromSample(line string) (PromSample, error) {
+	var s PromSample
+	i := strings.IndexAny(line, "{ \t")
+	if i < 0 {
+		return s, fmt.Errorf("invalid sample %q", line)
+	}
+	s.Name = line[:i]
+	rest := line[i:]
+	if rest[0] == '{' {
+		labels, n, err := parsePromLabels(rest)
+		if err != nil {
+			return s, fmt.Errorf("%v in %q", err, line)
+		}
+		s.Labels = labels
+		rest = rest[n:]
+	}
+	f := strings.Fields(rest)
+	if len(f) == 0 {
+		return s, fmt.Errorf("missing value in %q", line)
+	}
+	v, err := strconv.ParseFloat(f[0], 64)
+	if err != nil {
+		return s, fmt.Errorf("invalid value %q in %q", f[0], line)
+	}
+	s.Value = v
+	return s, nil
+}
+
+// parsePromLabels parses a {name="value",...} label set at the start of s.
+// It returns the labels sorted by name and the length of the label set.
+func parsePromLabels(s string) ([]KeyValue, int, error) {
+	var labels []KeyValue
+	i := 1
+	for {
+		for i < len(s) && (s[i] == ' ' || s[i] == ',') {
+			i++
+		}
+		if i >= len(s) {
+			return nil, 0, fmt.Errorf("unterminated label set")
+		}
+		if s[i] == '}' {
+			i++
+			break
+		}
+		eq := strings.IndexByte(s[i:], '=')
+		if eq < 0 || i+eq+1 >= len(s) || s[i+eq+1] != '"' {
+			return nil, 0, fmt.Errorf("invalid label")
+		}
+		name := strings.TrimSpace(s[i : i+eq])
+		value, n, ok := readPromString(s[i+eq+1:])
+		if !ok {
+			return nil, 0, fmt.Errorf("unterminated label value")
+		}
+		i += eq + 1 + n
+		labels = append(labels, KeyValue{Key: name, Value: value})
+	}
+	sort.Slice(labels, func(a, b int) bool { return labels[a].Key < labels[b].Key })
+	return labels, i, nil
+}
+
+// readPromString reads the double quoted string at the start of s, undoing
+// the \\, \" and \n escapes. It returns the string and the length of its
+// quoted form, ok is false when the closing quote is missing.
+func readPromString(s string) (value string, n int, ok bool) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			return b.String(), i + 1, true
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				if s[i] == 'n' {
+					b.WriteByte('\n')
+					continue
+				}
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return "", 0, false
+}
+
+// PromSelector selects samples by metric name and label matchers, using the
+// PromQL syntax: http_requests_total{code=~"5..",method!="get"}. The name may
+// be left out to select by labels only.
+type PromSelector struct {
+	Name     string
+	matchers []promMatcher
+}
+
+type promMatcher struct {
+	label string
+	op    string
+	value string
+	re    *regexp.Regexp
+}
+
+// ParsePromSelector parses a metric selector.
+func ParsePromSelector(sel string) (*PromSelector, error) {
+	sel = strings.TrimSpace(sel)
+	ps := &PromSelector{Name: sel}
+	i := strings.IndexByte(sel, '{')
+	if i < 0 {
+		return ps, nil
+	}
+	ps.Name = strings.TrimSpace(sel[:i])
+	if !strings.HasSuffix(sel, "}") {
+		return nil, fmt.Errorf("selector %q: missing '}'", sel)
+	}
+	body := sel[i+1 : len(sel)-1]
+	for body = strings.TrimSpace(body); body != ""; body = strings.TrimLeft(strings.TrimSpace(body), ", ") {
+		j := strings.IndexAny(body, "=!")
+		if j < 0 {
+			return nil, fmt.Errorf("selector %q: invalid matcher %q", sel, body)
+		}
+		m := promMatcher{label: strings.TrimSpace(body[:j])}
+		rest := body[j:]
+		for _, op := range []string{"=~", "!~", "!=", "="} {
+			if strings.HasPrefix(rest, op) {
+				m.op = op
+				break
+			}
+		}
+		rest = strings.TrimSpace(rest[len(m.op):])
+		if m.op == "" || !strings.HasPrefix(rest, `"`) {
+			return nil, fmt.Errorf("selector %q: invalid matcher %q", sel, body)
+		}
+		value, n, ok := readPromString(rest)
+		if !ok {
+			return nil, fmt.Errorf("selector %q: unterminated value", sel)
+		}
+		m.value = value
+		if m.op == "=~" || m.op == "!~" {
+			re, err := regexp.Compile("^(?:" + m.value + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("selector %q: %v", sel, err)
+			}
+			m.re = re
+		}
+		ps.matchers = append(ps.matchers, m)
+		body = rest[n:]
+	}
+	return ps, nil
+}
+
+// Matches reports whether the sample is selected.
+func (ps *PromSelector) Matches(s *PromSample) bool {
+	if ps.Name != "" && ps.Name != s.Name {
+		return false
+	}
+	for _, m := range ps.matchers {
+		v := s.Label(m.label)
+		var ok bool
+		switch m.op {
+		case "=":
+			ok = v == m.value
+		case "!=":
+			ok = v != m.value
+		case "=~":
+			ok = m.re.MatchString(v)
+		case "!~":
+			ok = !m.re.MatchString(v)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// isPromCounter reports whether the sample holds a cumulative value, based on
+// the declared type of its metric, or on the _total suffix when untyped.
+func isPromCounter(name string, types map[string]string) bool {
+	if types[name] == "counter" {
+		return true
+	}
+	for _, suffix := range []string{"_bucket", "_count", "_sum"} {
+		base := strings.TrimSuffix(name, suffix)
+		if base != name && (types[base] == "histogram" || types[base] == "summary") {
+			return true
+		}
+	}
+	_, typed := types[name]
+	return !typed && strings.HasSuffix(name, "_total")
+}
+
+// PromScraper polls a Prometheus /metrics endpoint and returns one record per
+// scrape, with a column per selected series. Counters are converted into per
+// second rates, their cell is empty in the first scrape of the series. Like
+// InfluxReader, columns are added as series appear.
+type PromScraper struct {
+	URL       string
+	Interval  time.Duration
+	Selectors []*PromSelector
+	Client    *http.Client
+	next      time.Time
+
+	mu       sync.Mutex
+	columns  map[string]int
+	names    []string
+	counters map[string]*Counter
+	scrapes  int
+	errors   int
+	lastErr  error
+	skipped  int64
+	pending  []string
+}
+
+// NewPromScraper returns a PromScraper polling url every interval.
+func NewPromScraper(url string, interval time.Duration, selectors []*PromSelector) *PromScraper {
+	return &PromScraper{
+		URL:       url,
+		Interval:  interval,
+		Selectors: selectors,
+		Client:    &http.Client{Timeout: interval},
+		columns:   map[string]int{},
+		counters:  map[string]*Counter{},
+	}
+}
+
+// Header performs the first scrape and returns the label column followed by
+// the selected series. The scraped record is returned by the next Read.
+func (p *PromScraper) Header() ([]string, error) {
+	record, err := p.scrape()
+	if err != nil {
+		return nil, err
+	}
+	p.pending = record
+	return append([]string{LabelColumn}, p.Columns()...), nil
+}
+
+// Read waits for the next scrape and returns it as a record. Failed scrapes
+// are counted and retried at the next interval.
+func (p *PromScraper) Read() ([]string, error) {
+	if record := p.pending; record != nil {
+		p.pending = nil
+		return record, nil
+	}
+	for {
+		time.Sleep(time.Until(p.next))
+		record, err := p.scrape()
+		if err == nil {
+			return record, nil
+		}
+	}
+}
+
+// Columns returns the names of the series seen so far. It is safe to call
+// concurrently with Read.
+func (p *PromScraper) Columns() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.names...)
+}
+
+// Skipped returns the number of malformed lines skipped in the scraped
+// documents. It is safe to call concurrently with Read.
+func (p *PromScraper) Skipped() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.skipped
+}
+
+// Status returns the number of scrapes, of failed scrapes and the last error.
+// It is safe to call concurrently with Read.
+func (p *PromScraper) Status() (scrapes, errors int, lastErr error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.scrapes, p.errors, p.lastErr
+}
+
+func (p *PromScraper) scrape() ([]string, error) {
+	now := time.Now()
+	p.next = now.Add(p.Interval)
+	samples, types, skipped, err := p.fetch()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.scrapes++
+	p.skipped += int64(skipped)
+	if err != nil {
+		p.errors++
+		p.lastErr = err
+		return nil, err
+	}
+	values := map[int]float64{}
+	for i := range samples {
+		s := &samples[i]
+		if !p.selected(s) {
+			continue
+		}
+		name := s.SeriesName()
+		col, ok := p.columns[name]
+		if !ok {
+			col = len(p.names)
+			p.columns[name] = col
+			p.names = append(p.names, name)
+		}
+		v := s.Value
+		if isPromCounter(s.Name, types) {
+			c, ok := p.counters[name]
+			if !ok {
+				c, _ = NewCounter(CounterRate)
+				p.counters[name] = c
+			}
+			//a rate needs two scrapes, the cell of the first is left empty
+			if v = c.Next(v, now); math.IsNaN(v) {
+				continue
+			}
+		}
+		values[col] = v
+	}
+	record := make([]string, len(p.names)+1)
+	record[0] = now.Format("15:04:05")
+	for col, v := range values {
+		record[col+1] = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return record, nil
+}
+
+func (p *PromScraper) fetch() ([]PromSample, map[string]string, int, error) {
+	req, err := http.NewRequest(http.MethodGet, p.URL, nil)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	req.Header.Set("Accept", "text/plain;version=0.0.4")
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, 0, fmt.Errorf("scrape %s: %s", p.URL, resp.Status)
+	}
+	return ParsePromText(resp.Body)
+}
+
+func (p *PromScraper) selected(s *PromSample) bool {
+	for _, sel := range p.Selectors {
+		if sel.Matches(s) {
+			return true
+		}
+	}
+	return false
+}
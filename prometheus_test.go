@@ -0,0 +1,213 @@
+package datadash
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParsePromText(t *testing.T) {
+	text := `# HELP http_requests_total The total number of requests.
+# TYPE http_requests_total counter
+http_requests_total{method="post",code="200"} 1027 1395066363000
+http_requests_total{code="400",method="post"} 3
+
+  # a comment
+temperature -1.5e1
+broken{code="200" 1
+no_value
+bad_value NaNa
+escaped{path="C:\\dir",quote="say \"hi\"",nl="a\nb"} +Inf
+`
+	samples, types, skipped, err := ParsePromText(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range samples {
+		got = append(got, s.SeriesName()+" "+strconv.FormatFloat(s.Value, 'g', -1, 64))
+	}
+	want := []string{
+		`http_requests_total{code="200",method="post"} 1027`,
+		`http_requests_total{code="400",method="post"} 3`,
+		`temperature -15`,
+		`escaped{nl="a\nb",path="C:\\dir",quote="say \"hi\""} +Inf`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParsePromText() samples = %q, want %q", got, want)
+	}
+	if !reflect.DeepEqual(types, map[string]string{"http_requests_total": "counter"}) {
+		t.Errorf("ParsePromText() types = %v", types)
+	}
+	if skipped != 3 {
+		t.Errorf("ParsePromText() skipped %d lines, want 3", skipped)
+	}
+}
+
+func TestParsePromLabels(t *testing.T) {
+	for _, tc := range []struct {
+		in     string
+		labels []KeyValue
+		n      int
+	}{
+		{in: "{} 1", n: 2},
+		{in: `{b="2",a="1"} 1`, labels: []KeyValue{{"a", "1"}, {"b", "2"}}, n: 13},
+		{in: `{ a="x,}" , } 1`, labels: []KeyValue{{"a", "x,}"}}, n: 13},
+		{in: `{a="\"\\\n"}`, labels: []KeyValue{{"a", "\"\\\n"}}, n: 12},
+	} {
+		labels, n, err := parsePromLabels(tc.in)
+		if err != nil {
+			t.Errorf("parsePromLabels(%q): %v", tc.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(labels, tc.labels) || n != tc.n {
+			t.Errorf("parsePromLabels(%q) = %v %d, want %v %d", tc.in, labels, n, tc.labels, tc.n)
+		}
+	}
+	for _, in := range []string{"{", `{a="1"`, `{a="1}`, `{a=1}`, `{a}`} {
+		if _, _, err := parsePromLabels(in); err == nil {
+			t.Errorf("parsePromLabels(%q) succeeded, want an error", in)
+		}
+	}
+}
+
+func TestPromSelector(t *testing.T) {
+	sample := func(name string, labels ...KeyValue) *PromSample {
+		return &PromSample{Name: name, Labels: labels}
+	}
+	ok200 := sample("http_requests_total", KeyValue{"code", "200"}, KeyValue{"method", "get"})
+	err500 := sample("http_requests_total", KeyValue{"code", "500"}, KeyValue{"method", "post"})
+	quoted := sample("log_lines_total", KeyValue{"msg", `say "hi"`})
+	for _, tc := range []struct {
+		sel  string
+		want []bool
+	}{
+		{sel: "http_requests_total", want: []bool{true, true, false}},
+		{sel: "log_lines_total", want: []bool{false, false, true}},
+		{sel: `{code="200"}`, want: []bool{true, false, false}},
+		{sel: `http_requests_total{code=~"5.."}`, want: []bool{false, true, false}},
+		{sel: `http_requests_total{code!="200",method="post"}`, want: []bool{false, true, false}},
+		{sel: `http_requests_total{method!~"g.*"}`, want: []bool{false, true, false}},
+		{sel: `{code=~"2.."  ,  }`, want: []bool{true, false, false}},
+		{sel: `{msg="say \"hi\""}`, want: []bool{false, false, true}},
+		{sel: `{msg=~"say \".*"}`, want: []bool{false, false, true}},
+		{sel: `{code=""}`, want: []bool{false, false, true}},
+	} {
+		ps, err := ParsePromSelector(tc.sel)
+		if err != nil {
+			t.Errorf("ParsePromSelector(%q): %v", tc.sel, err)
+			continue
+		}
+		var got []bool
+		for _, s := range []*PromSample{ok200, err500, quoted} {
+			got = append(got, ps.Matches(s))
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("ParsePromSelector(%q).Matches() = %v, want %v", tc.sel, got, tc.want)
+		}
+	}
+	for _, sel := range []string{`up{code="200"`, `up{code}`, `up{code=200}`, `up{code="200}`, `up{code=~"("}`} {
+		if _, err := ParsePromSelector(sel); err == nil {
+			t.Errorf("ParsePromSelector(%q) succeeded, want an error", sel)
+		}
+	}
+}
+
+func TestIsPromCounter(t *testing.T) {
+	types := map[string]string{
+		"requests":   "counter",
+		"latency":    "histogram",
+		"size":       "summary",
+		"temp_total": "gauge",
+	}
+	for _, tc := range []struct {
+		name string
+		want bool
+	}{
+		{"requests", true},
+		{"latency_bucket", true},
+		{"latency_count", true},
+		{"latency_sum", true},
+		{"size_count", true},
+		{"size", false},
+		{"temp_total", false},
+		{"errors_total", true},
+		{"errors", false},
+		{"requests_count", false},
+	} {
+		if got := isPromCounter(tc.name, types); got != tc.want {
+			t.Errorf("isPromCounter(%q) = %t, want %t", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestPromScraper(t *testing.T) {
+	var requests int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&requests, 1)
+		if n == 2 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "# TYPE jobs_done_total counter")
+		fmt.Fprintf(w, "jobs_done_total %d\n", n*100)
+		fmt.Fprintln(w, "queue_depth 7")
+		fmt.Fprintln(w, "ignored 1")
+		fmt.Fprintln(w, "broken{")
+		if n > 1 {
+			fmt.Fprintln(w, `queue_depth{queue="slow"} 3`)
+		}
+	}))
+	defer srv.Close()
+
+	p := NewPromScraper(srv.URL, 50*time.Millisecond, []*PromSelector{{Name: "jobs_done_total"}, {Name: "queue_depth"}})
+	header, err := p.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"x", "jobs_done_total", "queue_depth"}; !reflect.DeepEqual(header, want) {
+		t.Errorf("Header() = %q, want %q", header, want)
+	}
+	record, err := p.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record[1] != "" || record[2] != "7" {
+		t.Errorf("first Read() = %q, want an empty rate and 7", record)
+	}
+
+	//the second scrape fails and is retried
+	start := time.Now()
+	record, err = p.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	elapsed := time.Since(start).Seconds()
+	if len(record) != 4 || record[2] != "7" || record[3] != "3" {
+		t.Fatalf("second Read() = %q, want 4 fields", record)
+	}
+	rate, err := strconv.ParseFloat(record[1], 64)
+	if err != nil {
+		t.Fatalf("second Read() rate %q: %v", record[1], err)
+	}
+	//200 jobs done since the first scrape, at least 2 intervals ago
+	if rate <= 0 || rate > 200/0.1 || rate < 200/(elapsed+1) {
+		t.Errorf("second Read() rate = %v, want 200 per %.2fs", rate, elapsed)
+	}
+	if want := []string{"jobs_done_total", "queue_depth", `queue_depth{queue="slow"}`}; !reflect.DeepEqual(p.Columns(), want) {
+		t.Errorf("Columns() = %q, want %q", p.Columns(), want)
+	}
+	scrapes, errors, lastErr := p.Status()
+	if scrapes != 3 || errors != 1 || lastErr == nil {
+		t.Errorf("Status() = %d, %d, %v, want 3 scrapes and 1 error", scrapes, errors, lastErr)
+	}
+	if p.Skipped() != 2 {
+		t.Errorf("Skipped() = %d, want 2", p.Skipped())
+	}
+}
@@ -0,0 +1,172 @@
+package datadash
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Quality checks the records of an input for data quality problems: ragged
+// records, cells which aren't numbers, duplicate labels and timestamps out
+// of order. It counts them and keeps the last offending records. It is safe
+// for concurrent use.
+type Quality struct {
+	// FixedFields reports records with another number of fields than the
+	// header as ragged. Leave it unset for readers adding columns on the fly.
+	FixedFields bool
+	// SharedLabels accepts records with the label of the previous record,
+	// like the records grouped into one bucket.
+	SharedLabels bool
+	// Delimiter joins the fields of the offending records shown.
+	Delimiter string
+
+	mu         sync.Mutex
+	header     []string
+	labeled    bool
+	keep       int
+	records    int
+	ragged     int
+	duplicates int
+	outOfOrder int
+	unparsable []int
+	lastLabel  string
+	lastTime   time.Time
+	timed      bool
+	lines      []string
+}
+
+// QualityStatus is a summary of the problems found by a Quality.
+type QualityStatus struct {
+	Records    int
+	Ragged     int
+	Duplicates int
+	OutOfOrder int
+	// Unparsable is the number of cells which aren't numbers per column,
+	// columns without any are left out.
+	Unparsable map[string]int
+	// Lines are the last offending records, prefixed with their line number
+	// and problems.
+	Lines []string
+}
+
+// Problems returns the total number of problems.
+func (s QualityStatus) Problems() int {
+	n := s.Ragged + s.Duplicates + s.OutOfOrder
+	for _, c := range s.Unparsable {
+		n += c
+	}
+	return n
+}
+
+// NewQuality returns a Quality for records of the columns named by header,
+// keeping the last keep offending records. The first field of a record is
+// its label unless the header has a single column.
+func NewQuality(header []string, keep int) *Quality {
+	return &Quality{
+		Delimiter: "\t",
+		header:    header,
+		labeled:   len(header) > 1,
+		keep:      keep,
+	}
+}
+
+// Check checks the next record, read from line of the input or 0 when the
+// line isn't known. It returns false when the record has a problem.
+func (q *Quality) Check(line int, record []string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.records++
+	var problems []string
+	if q.FixedFields && len(record) != len(q.header) {
+		q.ragged++
+		problems = append(problems, fmt.Sprintf("%d fields, expected %d", len(record), len(q.header)))
+	}
+	first := 0
+	if q.labeled && len(record) > 0 {
+		first = 1
+		if p := q.checkLabel(record[0]); p != "" {
+			problems = append(problems, p)
+		}
+	}
+	for i := first; i < len(record); i++ {
+		cell := strings.TrimSpace(record[i])
+		if cell == "" {
+			continue
+		}
+		if _, err := strconv.ParseFloat(cell, 64); err != nil {
+			for len(q.unparsable) <= i {
+				q.unparsable = append(q.unparsable, 0)
+			}
+			q.unparsable[i]++
+			problems = append(problems, fmt.Sprintf("%s is %q", q.column(i), cell))
+		}
+	}
+	if len(problems) == 0 {
+		return true
+	}
+	where := fmt.Sprintf("record %d", q.records)
+	if line > 0 {
+		where = fmt.Sprintf("line %d", line)
+	}
+	q.lines = append(q.lines, where+": "+strings.Join(problems, ", ")+": "+strings.Join(record, q.Delimiter))
+	if len(q.lines) > q.keep {
+		q.lines = q.lines[len(q.lines)-q.keep:]
+	}
+	return false
+}
+
+// checkLabel compares a label with the one of the previous record, labels
+// which are timestamps must not go back in time.
+func (q *Quality) checkLabel(label string) string {
+	label = strings.TrimSpace(label)
+	previous := q.lastLabel
+	q.lastLabel = label
+	if label == "" {
+		return ""
+	}
+	if label == previous && !q.SharedLabels {
+		q.duplicates++
+		return "duplicate label"
+	}
+	ts, ok := ParseTimestamp(label)
+	if !ok {
+		return ""
+	}
+	before, timed := q.lastTime, q.timed
+	q.lastTime, q.timed = ts, true
+	if timed && ts.Before(before) {
+		q.outOfOrder++
+		return "out of order"
+	}
+	return ""
+}
+
+// column returns the name of the i-th field of a record.
+func (q *Quality) column(i int) string {
+	if i < len(q.header) && strings.TrimSpace(q.header[i]) != "" {
+		return strings.TrimSpace(q.header[i])
+	}
+	return fmt.Sprintf("col%d", i+1)
+}
+
+// Status returns the problems found so far.
+func (q *Quality) Status() QualityStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	s := QualityStatus{
+		Records:    q.records,
+		Ragged:     q.ragged,
+		Duplicates: q.duplicates,
+		OutOfOrder: q.outOfOrder,
+		Unparsable: map[string]int{},
+		Lines:      append([]string(nil), q.lines...),
+	}
+	for i, n := range q.unparsable {
+		if n > 0 {
+			s.Unparsable[q.column(i)] += n
+		}
+	}
+	return s
+}
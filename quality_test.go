@@ -0,0 +1,66 @@
+package datadash
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQuality(t *testing.T) {
+	q := NewQuality([]string{"x", "latency", "errors"}, 2)
+	q.Delimiter = ","
+	for i, rec := range [][]string{
+		{"10:00:01", "5", "1"},
+		{"10:00:02", "6"},
+		{"10:00:02", "7", "oops"},
+		{"10:00:01", "8", "2", "9"},
+		{"10:00:03", "", " 3 "},
+	} {
+		q.Check(0, rec)
+		if i == 0 && q.Status().Problems() != 0 {
+			t.Fatalf("problems found in a valid record: %+v", q.Status())
+		}
+	}
+	want := QualityStatus{
+		Records:    5,
+		Duplicates: 1,
+		OutOfOrder: 1,
+		Unparsable: map[string]int{"errors": 1},
+		Lines: []string{
+			`record 3: duplicate label, errors is "oops": 10:00:02,7,oops`,
+			`record 4: out of order: 10:00:01,8,2,9`,
+		},
+	}
+	if got := q.Status(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Status() = %+v, want %+v", got, want)
+	}
+
+	q.FixedFields = true
+	if q.Check(7, []string{"10:00:04", "1"}) {
+		t.Error("Check() of a ragged record = true")
+	}
+	s := q.Status()
+	if s.Ragged != 1 || s.Problems() != 4 || s.Lines[1] != "line 7: 2 fields, expected 3: 10:00:04,1" {
+		t.Errorf("Status() = %+v, want 1 ragged record of line 7 and 4 problems", s)
+	}
+}
+
+func TestQualityUnlabeled(t *testing.T) {
+	q := NewQuality([]string{"value"}, 3)
+	for _, rec := range [][]string{{"1"}, {"1"}, {"x"}} {
+		q.Check(0, rec)
+	}
+	if s := q.Status(); s.Duplicates != 0 || s.Unparsable["value"] != 1 {
+		t.Errorf("Status() = %+v, want the values checked and no labels", s)
+	}
+}
+
+func TestQualitySharedLabels(t *testing.T) {
+	q := NewQuality([]string{"x", "latency"}, 2)
+	q.SharedLabels = true
+	for _, rec := range [][]string{{"10:00:00", "1"}, {"10:00:00", "2"}, {"09:59:59", "3"}} {
+		q.Check(0, rec)
+	}
+	if s := q.Status(); s.Duplicates != 0 || s.OutOfOrder != 1 {
+		t.Errorf("Status() = %+v, want records sharing a label accepted", s)
+	}
+}
@@ -0,0 +1,106 @@
+package datadash
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sync/atomic"
+)
+
+// LabelColumn is the name of the X-Axis label column of readers which build
+// their own header, and of the capture group used as label by RegexReader.
+const LabelColumn = "x"
+
+// RegexReader reads records out of arbitrary lines of text using the named
+// capture groups of a regular expression, one column per group. Lines which
+// don't match are counted and skipped.
+type RegexReader struct {
+	re        *regexp.Regexp
+	scanner   *bufio.Scanner
+	label     int
+	columns   []int
+	unmatched int64
+}
+
+// NewRegexReader returns a RegexReader reading lines from r. The expression
+// must have at least one named capture group, a group named "x" is used as
+// X-Axis label.
+func NewRegexReader(r io.Reader, expr string) (*RegexReader, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("regex: %v", err)
+	}
+	rr := &RegexReader{
+		re:      re,
+		scanner: bufio.NewScanner(r),
+		label:   -1,
+	}
+	rr.scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for i, name := range re.SubexpNames() {
+		switch name {
+		case "":
+		case LabelColumn:
+			rr.label = i
+		default:
+			rr.columns = append(rr.columns, i)
+		}
+	}
+	if len(rr.columns) == 0 {
+		return nil, fmt.Errorf("regex %q: no named capture groups, use (?P<name>...)", expr)
+	}
+	return rr, nil
+}
+
+// Header returns the column names: the label followed by the capture groups.
+func (r *RegexReader) Header() []string {
+	names := r.re.SubexpNames()
+	header := []string{LabelColumn}
+	for _, i := range r.columns {
+		header = append(header, names[i])
+	}
+	return header
+}
+
+// Columns returns the names of the capture groups.
+func (r *RegexReader) Columns() []string {
+	return r.Header()[1:]
+}
+
+// HasLabel reports whether the expression captures the X-Axis label. Without
+// it the first field of every record is empty.
+func (r *RegexReader) HasLabel() bool {
+	return r.label >= 0
+}
+
+// Read returns the next matching line as a record. It returns io.EOF once the
+// input is exhausted.
+func (r *RegexReader) Read() ([]string, error) {
+	for r.scanner.Scan() {
+		m := r.re.FindStringSubmatch(r.scanner.Text())
+		if m == nil {
+			atomic.AddInt64(&r.unmatched, 1)
+			continue
+		}
+		record := make([]string, 0, len(r.columns)+1)
+		if r.label >= 0 {
+			record = append(record, m[r.label])
+		} else {
+			record = append(record, "")
+		}
+		for _, i := range r.columns {
+			record = append(record, m[i])
+		}
+		return record, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// Unmatched returns the number of lines skipped so far. It is safe to call
+// concurrently with Read.
+func (r *RegexReader) Unmatched() int64 {
+	return atomic.LoadInt64(&r.unmatched)
+}
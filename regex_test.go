@@ -0,0 +1,91 @@
+package datadash
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRegexReader(t *testing.T) {
+	lines := "10:00:01 GET /a 200 12ms\n" +
+		"starting worker\n" +
+		"10:00:02 GET /b 500 340ms\n" +
+		"\n" +
+		"10:00:03 POST /c 201 8ms\n"
+	for _, tc := range []struct {
+		name    string
+		expr    string
+		header  []string
+		label   bool
+		records [][]string
+		unmatch int64
+	}{
+		{
+			name:    "label and groups",
+			expr:    `^(?P<x>\S+) \S+ \S+ (?P<status>\d+) (?P<latency>\d+)ms`,
+			header:  []string{"x", "status", "latency"},
+			label:   true,
+			records: [][]string{{"10:00:01", "200", "12"}, {"10:00:02", "500", "340"}, {"10:00:03", "201", "8"}},
+			unmatch: 2,
+		},
+		{
+			name:    "without label",
+			expr:    `(?P<latency>\d+)ms`,
+			header:  []string{"x", "latency"},
+			records: [][]string{{"", "12"}, {"", "340"}, {"", "8"}},
+			unmatch: 2,
+		},
+		{
+			name:    "unnamed groups are ignored",
+			expr:    `(GET|POST) \S+ (?P<status>5\d\d)`,
+			header:  []string{"x", "status"},
+			records: [][]string{{"", "500"}},
+			unmatch: 4,
+		},
+	} {
+		r, err := NewRegexReader(strings.NewReader(lines), tc.expr)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if got := r.Header(); !reflect.DeepEqual(got, tc.header) {
+			t.Errorf("%s: Header() = %q, want %q", tc.name, got, tc.header)
+		}
+		if !reflect.DeepEqual(r.Columns(), tc.header[1:]) || r.HasLabel() != tc.label {
+			t.Errorf("%s: Columns() = %q, HasLabel() = %t", tc.name, r.Columns(), r.HasLabel())
+		}
+		var records [][]string
+		for {
+			rec, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: Read(): %v", tc.name, err)
+			}
+			records = append(records, rec)
+		}
+		if !reflect.DeepEqual(records, tc.records) {
+			t.Errorf("%s: records = %q, want %q", tc.name, records, tc.records)
+		}
+		if got := r.Unmatched(); got != tc.unmatch {
+			t.Errorf("%s: Unmatched() = %d, want %d", tc.name, got, tc.unmatch)
+		}
+	}
+}
+
+func TestRegexReaderErrors(t *testing.T) {
+	for _, tc := range []struct {
+		expr, err string
+	}{
+		{`(\d+)ms`, "no named capture groups"},
+		{`latency=\d+`, "no named capture groups"},
+		{`(?P<x>\S+) (\d+)`, "no named capture groups"},
+		{`(?P<latency>\d+`, "regex: "},
+	} {
+		if _, err := NewRegexReader(strings.NewReader(""), tc.expr); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("NewRegexReader(%q) = %v, want an error containing %q", tc.expr, err, tc.err)
+		}
+	}
+}
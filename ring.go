@@ -1,103 +1,127 @@
 package datadash
 
-type float64RingBuffer struct {
-	buffer   []float64
-	length   int
-	capacity int
-	tail     int
+import (
+	"sort"
+	"time"
+)
+
+// RingBuffer keeps the last Capacity values added to it, along with the time
+// they were added at. The read methods copy into a destination supplied by
+// the caller, so reading doesn't allocate once the destination is large
+// enough.
+type RingBuffer[T any] struct {
+	buffer []T
+	times  []time.Time
+	length int
+	// tail is the index the next value is written to
+	tail int
 }
 
-func newFloat64RingBuffer(capacity int) *float64RingBuffer {
-	return &float64RingBuffer{
-		buffer:   make([]float64, capacity, capacity),
-		length:   0,
-		capacity: capacity,
-		tail:     0,
+// NewRingBuffer returns an empty ring buffer holding up to capacity values.
+func NewRingBuffer[T any](capacity int) *RingBuffer[T] {
+	return &RingBuffer[T]{
+		buffer: make([]T, capacity),
+		times:  make([]time.Time, capacity),
 	}
 }
 
-func (r *float64RingBuffer) Len() int {
+// Len returns the number of values in the buffer.
+func (r *RingBuffer[T]) Len() int {
 	return r.length
 }
 
-func (r *float64RingBuffer) Capacity() int {
-	return r.capacity
+// Capacity returns the maximum number of values in the buffer.
+func (r *RingBuffer[T]) Capacity() int {
+	return len(r.buffer)
 }
 
-func (r *float64RingBuffer) Add(v float64) {
-	if r.length < r.capacity {
-		r.length += 1
-	}
-	r.buffer[r.tail] = v
-	r.tail = (r.tail + 1) % r.capacity
+// Add adds v, replacing the oldest value once the buffer is full.
+func (r *RingBuffer[T]) Add(v T) {
+	r.AddAt(time.Time{}, v)
 }
 
-func (r *float64RingBuffer) Slice(i, j int) []float64 {
-	if r.length < r.capacity {
-		if r.length < j {
-			j = r.length
-		}
-		return r.buffer[i:j]
+// AddAt adds v observed at ts. Lookup expects values to be added in time
+// order.
+func (r *RingBuffer[T]) AddAt(ts time.Time, v T) {
+	if len(r.buffer) == 0 {
+		return
 	}
-	s := append(r.buffer[r.tail:r.capacity], r.buffer[:r.tail]...)
-	return s[i:j]
-}
-
-func (r *float64RingBuffer) Last(n int) []float64 {
-	start := r.length - n
-	if start < 0 {
-		start = 0
+	if r.length < len(r.buffer) {
+		r.length++
 	}
-	return r.Slice(start, r.length)
+	r.buffer[r.tail] = v
+	r.times[r.tail] = ts
+	r.tail = (r.tail + 1) % len(r.buffer)
 }
 
-type stringRingBuffer struct {
-	buffer   []string
-	length   int
-	capacity int
-	tail     int
+// index returns the index in buffer of the i-th oldest value.
+func (r *RingBuffer[T]) index(i int) int {
+	return (r.tail - r.length + i + len(r.buffer)) % len(r.buffer)
 }
 
-func newStringRingBuffer(capacity int) *stringRingBuffer {
-	return &stringRingBuffer{
-		buffer:   make([]string, capacity, capacity),
-		length:   0,
-		capacity: capacity,
-		tail:     0,
-	}
+// At returns the i-th oldest value, i must be less than Len.
+func (r *RingBuffer[T]) At(i int) T {
+	return r.buffer[r.index(i)]
 }
 
-func (r *stringRingBuffer) Len() int {
-	return r.length
-}
-
-func (r *stringRingBuffer) Capacity() int {
-	return r.capacity
+// Copy copies values into dst, starting with the i-th oldest, and returns the
+// number of values copied: the smaller of len(dst) and Len()-i.
+func (r *RingBuffer[T]) Copy(dst []T, i int) int {
+	if i < 0 || i >= r.length {
+		return 0
+	}
+	n := 0
+	start := r.index(i)
+	end := start + r.length - i
+	if end > len(r.buffer) {
+		n = copy(dst, r.buffer[start:])
+		if n < len(dst) {
+			n += copy(dst[n:], r.buffer[:end-len(r.buffer)])
+		}
+		return n
+	}
+	return copy(dst, r.buffer[start:end])
 }
 
-func (r *stringRingBuffer) Add(v string) {
-	if r.length < r.capacity {
-		r.length += 1
+// AppendLast appends the last n values, oldest first, to dst and returns the
+// extended slice.
+func (r *RingBuffer[T]) AppendLast(dst []T, n int) []T {
+	if n > r.length {
+		n = r.length
 	}
-	r.buffer[r.tail] = v
-	r.tail = (r.tail + 1) % r.capacity
+	if n <= 0 {
+		return dst
+	}
+	l := len(dst)
+	if cap(dst)-l < n {
+		grown := make([]T, l, l+n)
+		copy(grown, dst)
+		dst = grown
+	}
+	dst = dst[:l+n]
+	r.Copy(dst[l:], r.length-n)
+	return dst
 }
 
-func (r *stringRingBuffer) Slice(i, j int) []string {
-	if r.length < r.capacity {
-		if r.length < j {
-			j = r.length
+// Each calls fn with the values from the oldest to the newest, until fn
+// returns false.
+func (r *RingBuffer[T]) Each(fn func(i int, v T) bool) {
+	for i := 0; i < r.length; i++ {
+		if !fn(i, r.buffer[r.index(i)]) {
+			return
 		}
-		return r.buffer[i:j]
 	}
-	s := append(r.buffer[r.tail:r.capacity], r.buffer[:r.tail]...)
-	return s[i:j]
 }
 
-func (r *stringRingBuffer) Last(n int) []string {
-	start := r.length - n
-	if start < 0 {
-		start = 0
+// Lookup returns the newest value added at or before ts. It returns false
+// when all values are newer than ts.
+func (r *RingBuffer[T]) Lookup(ts time.Time) (T, bool) {
+	i := sort.Search(r.length, func(i int) bool {
+		return r.times[r.index(i)].After(ts)
+	})
+	if i == 0 {
+		var zero T
+		return zero, false
 	}
-	return r.Slice(start, r.length)
+	return r.buffer[r.index(i-1)], true
 }
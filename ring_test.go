@@ -0,0 +1,162 @@
+package datadash
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRingBuffer(t *testing.T) {
+	r := NewRingBuffer[int](4)
+	if got := r.AppendLast(nil, 3); len(got) != 0 {
+		t.Errorf("AppendLast of an empty buffer = %v", got)
+	}
+	for i := 1; i <= 6; i++ {
+		r.Add(i)
+	}
+	if r.Len() != 4 || r.Capacity() != 4 {
+		t.Fatalf("Len, Capacity = %d, %d, want 4, 4", r.Len(), r.Capacity())
+	}
+	if got, want := r.AppendLast(nil, 10), []int{3, 4, 5, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AppendLast(10) = %v, want %v", got, want)
+	}
+	if got, want := r.AppendLast([]int{0}, 2), []int{0, 5, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AppendLast(2) = %v, want %v", got, want)
+	}
+	dst := make([]int, 3)
+	if n := r.Copy(dst, 2); n != 2 || !reflect.DeepEqual(dst[:n], []int{5, 6}) {
+		t.Errorf("Copy(dst, 2) = %d %v, want 2 [5 6]", n, dst[:n])
+	}
+	if n := r.Copy(dst, 0); n != 3 || !reflect.DeepEqual(dst, []int{3, 4, 5}) {
+		t.Errorf("Copy(dst, 0) = %d %v, want 3 [3 4 5]", n, dst)
+	}
+	var each []int
+	r.Each(func(i int, v int) bool {
+		each = append(each, v)
+		return i < 2
+	})
+	if want := []int{3, 4, 5}; !reflect.DeepEqual(each, want) {
+		t.Errorf("Each = %v, want %v", each, want)
+	}
+}
+
+// TestRingBufferReads checks reading a wrapped buffer leaves it unchanged,
+// the previous implementation appended into its own backing array.
+func TestRingBufferReads(t *testing.T) {
+	r := NewRingBuffer[string](3)
+	for i := 0; i < 5; i++ {
+		r.Add(strconv.Itoa(i))
+	}
+	for i := 0; i < 3; i++ {
+		if got, want := r.AppendLast(nil, 3), []string{"2", "3", "4"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("read %d: AppendLast(3) = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestRingBufferLookup(t *testing.T) {
+	start := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	r := NewRingBuffer[float64](3)
+	for i := 0; i < 5; i++ {
+		r.AddAt(start.Add(time.Duration(i)*time.Second), float64(i))
+	}
+	for _, tc := range []struct {
+		at   time.Duration
+		want float64
+		ok   bool
+	}{
+		{at: time.Second, ok: false},
+		{at: 2 * time.Second, want: 2, ok: true},
+		{at: 3500 * time.Millisecond, want: 3, ok: true},
+		{at: time.Hour, want: 4, ok: true},
+	} {
+		got, ok := r.Lookup(start.Add(tc.at))
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("Lookup(+%s) = %v, %t, want %v, %t", tc.at, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+// TestRenderAllocs checks the reads done on every redraw don't allocate once
+// the destination has grown.
+func TestRenderAllocs(t *testing.T) {
+	r := NewRow("latency", BufferSize(100))
+	for i := 0; i < 150; i++ {
+		r.Update(float64(i), strconv.Itoa(i), 10)
+	}
+	var s Snapshot
+	for _, n := range []int{50, -1} {
+		r.SnapshotInto(&s, n)
+		if allocs := testing.AllocsPerRun(100, func() { r.SnapshotInto(&s, n) }); allocs != 0 {
+			t.Errorf("SnapshotInto(%d) allocates %v times per call", n, allocs)
+		}
+	}
+}
+
+func BenchmarkRingBufferAdd(b *testing.B) {
+	r := NewRingBuffer[float64](1440)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.Add(float64(i))
+	}
+}
+
+func BenchmarkRingBufferAppendLast(b *testing.B) {
+	r := NewRingBuffer[float64](1440)
+	for i := 0; i < 2000; i++ {
+		r.Add(float64(i))
+	}
+	dst := make([]float64, 0, 1440)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dst = r.AppendLast(dst[:0], 1000)
+	}
+}
+
+func BenchmarkRingBufferEach(b *testing.B) {
+	r := NewRingBuffer[float64](1440)
+	for i := 0; i < 2000; i++ {
+		r.Add(float64(i))
+	}
+	var sum float64
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Each(func(_ int, v float64) bool {
+			sum += v
+			return true
+		})
+	}
+}
+
+func BenchmarkRingBufferLookup(b *testing.B) {
+	start := time.Now()
+	r := NewRingBuffer[float64](1440)
+	for i := 0; i < 2000; i++ {
+		r.AddAt(start.Add(time.Duration(i)*time.Second), float64(i))
+	}
+	at := start.Add(1500 * time.Second)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Lookup(at)
+	}
+}
+
+// BenchmarkRowSnapshotInto measures the copy made by the widgets on every
+// redraw of a scrolling row.
+func BenchmarkRowSnapshotInto(b *testing.B) {
+	r := NewRow("latency")
+	for i := 0; i < 2000; i++ {
+		r.Update(float64(i), strconv.Itoa(i), DefaultAverageSeek)
+	}
+	var s Snapshot
+	r.SnapshotInto(&s, 200)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.SnapshotInto(&s, 200)
+	}
+}
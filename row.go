@@ -3,9 +3,12 @@ package datadash
 import (
 	"context"
 	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
 	"time"
 
-	"github.com/montanaflynn/stats"
 	"github.com/mum4k/termdash/cell"
 	"github.com/mum4k/termdash/container"
 	"github.com/mum4k/termdash/linestyle"
@@ -15,13 +18,6 @@ import (
 	"github.com/mum4k/termdash/widgets/text"
 )
 
-type Panel interface {
-	NewRow() *Row
-	InitWidgets() *Row
-	NewContainer() []container.Option
-	Update()
-}
-
 // row types
 const (
 	scrolling = iota
@@ -37,157 +33,177 @@ const (
 )
 
 var (
-	parOneBorder     = 26
-	parOneTitle      = 82
-	parTwoBorder     = 25
-	parTwoTitle      = 13
-	parThreeBorder   = 25
-	parThreeTitle    = 45
-	parFourBorder    = 25
-	parFourTitle     = 9
-	parFiveTitle     = 165
-	parFiveBorder    = 25
-	parText          = 3
-	parPointer       = 248
-	parValue         = 15
-	graphOneBorder   = 25
-	graphTwoBorder   = 25
-	graphThreeBorder = 25
-	graphFourBorder  = 25
-	graphFiveBorder  = 25
-	graphAxes        = 8
-	graphXLabels     = 248
-	graphYLabels     = 15
-	graphTitles      = 2
-	lineLow          = 235
-	lineAvg          = 235
-	lineHigh         = 239
-	graphLineOne     = 82
-	graphLineTwo     = 13
-	graphLineThree   = 45
-	graphLineFour    = 9
-	graphLineFive    = 165
-	graphLinePaused  = 1
-	ctx              context.Context
+	ctx context.Context
 )
 
-//type RowInterface interface {
-//	NewRow()      // same as adding the methods of ReadWriter
-//	InitWidgets() // same as adding the methods of Locker
-//	returnChart()
-//}
-
 type Row struct {
-	ID               int
-	Label            string
-	Scroll           bool
-	Average          bool
-	Labels           *stringRingBuffer
-	Context          context.Context
-	Data             *float64RingBuffer
-	Averages         *float64RingBuffer
-	LineChart        *linechart.LineChart
-	YAxisAdaptive    bool
-	BarChart         *barchart.BarChart
-	SparkLine        *sparkline.SparkLine
-	Textbox          *text.Text
-	DataContainer    []float64
-	LabelContainer   []string
-	AverageContainer []float64
-	RedrawInterval   time.Duration
-	SeekInterval     time.Duration
+	ID             int
+	Label          string
+	Scroll         bool
+	Average        bool
+	Context        context.Context
+	LineChart      *linechart.LineChart
+	YAxisAdaptive  bool
+	BarChart       *barchart.BarChart
+	SparkLine      *sparkline.SparkLine
+	Textbox        *text.Text
+	RedrawInterval time.Duration
+	SeekInterval   time.Duration
+	Theme          *Theme
+	// Clock times the updates and redraws, RealClock when nil.
+	Clock Clock
+	// Baseline is the row of a previous run, drawn dimmed in the line chart
+	// and compared against in the statistics panel.
+	Baseline *Row
+
+	// mu guards the fields below. Update may be called concurrently with
+	// the widgets, which read the data through Snapshot.
+	mu       sync.RWMutex
+	data     *RingBuffer[float64]
+	labels   *RingBuffer[string]
+	averages *RingBuffer[float64]
+	//means are the averages of all values added up to each of them, total
+	//is their sum
+	means *RingBuffer[float64]
+	total float64
+	// overlayRows are drawn as additional series in this row's line chart,
+	// added by AddOverlay.
+	overlayRows []*Row
+	// updates counts the calls of Update, telling the widgets whether there
+	// is anything new to draw
+	updates uint64
+}
+
+// Snapshot is a copy of the data of a row, it is not changed by later
+// updates.
+type Snapshot struct {
+	Values   []float64
+	Labels   []string
+	Averages []float64
 }
 
 //func (self *Row) increment() {
 //	self.id++
 //}
 
-func NewRow(ctx context.Context, label string, bufsize int, id int, scroll bool, average bool, yAxisAdaptive bool) *Row {
+// NewRow returns a row drawing the values labelled label. The options
+// SeriesID, BufferSize, Scroll, AverageLine, YAxisAdaptive, ColorTheme and
+// UseClock apply, the others are ignored.
+func NewRow(label string, opts ...Option) *Row {
+	c := newConfig(opts)
+	if c.id < 0 {
+		c.id = 0
+	}
 	row := &Row{
-		ID:            id,
-		Scroll:        scroll,
-		YAxisAdaptive: yAxisAdaptive,
-		Average:       average,
+		ID:            c.id,
+		Scroll:        c.scroll,
+		YAxisAdaptive: c.yAxisAdaptive,
+		Average:       c.average,
 		Label:         label,
-		Context:       ctx,
-		Data:          newFloat64RingBuffer(bufsize),
-		Labels:        newStringRingBuffer(bufsize),
-		Averages:      newFloat64RingBuffer(bufsize),
+		Theme:         c.theme,
+		Clock:         c.clock,
+		data:          NewRingBuffer[float64](c.bufferSize),
+		labels:        NewRingBuffer[string](c.bufferSize),
+		averages:      NewRingBuffer[float64](c.bufferSize),
+		means:         NewRingBuffer[float64](c.bufferSize),
 	}
 	return row
 }
 
-func (r *Row) InitWidgets(ctx context.Context, graphType string, label string, reDrawInterval time.Duration, seekInterval time.Duration) *Row {
+// InitWidgets creates the statistics text, the line chart and, for the bar
+// and spark graph types, the bar chart or sparkline of the row. It returns
+// the error of the first widget which can't be created.
+func (r *Row) InitWidgets(ctx context.Context, graphType string, label string, reDrawInterval time.Duration, seekInterval time.Duration) error {
 	r.Label = label
 	r.RedrawInterval = reDrawInterval
 	r.SeekInterval = seekInterval
-	r.Textbox = r.newTextBox(ctx, label)
-	r.LineChart = r.newLineChart(ctx)
-
+	var err error
+	if r.Textbox, err = r.newText(ctx, label); err != nil {
+		return fmt.Errorf("text of %q: %v", label, err)
+	}
+	if r.LineChart, err = r.createLineChart(ctx); err != nil {
+		return fmt.Errorf("line chart of %q: %v", label, err)
+	}
 	if graphType == "bar" {
-		r.BarChart = r.newBarChart(ctx)
+		if r.BarChart, err = r.createBarGraph(ctx); err != nil {
+			return fmt.Errorf("bar chart of %q: %v", label, err)
+		}
 	}
 	if graphType == "spark" {
-		r.SparkLine = r.newSparkLine(ctx)
+		if r.SparkLine, err = r.createSparkLine(ctx); err != nil {
+			return fmt.Errorf("sparkline of %q: %v", label, err)
+		}
 	}
-	return r
+	return nil
 }
 
-func (r *Row) newLineChart(ctx context.Context) *linechart.LineChart {
-	lc, err := r.createLineChart(ctx)
-	if err != nil {
-		fmt.Println("LineChart Error:", err)
-	}
-	return lc
+// Snapshot returns a copy of the last n values, labels and moving averages
+// of the row. When n is negative it returns all the values the row holds,
+// with the averages of all values added up to each of them instead. It is
+// safe to call concurrently with Update.
+func (r *Row) Snapshot(n int) Snapshot {
+	var s Snapshot
+	r.SnapshotInto(&s, n)
+	return s
 }
-func (r *Row) newTextBox(ctx context.Context, label string) *text.Text {
-	t, err := r.newText(ctx, label)
-	if err != nil {
-		fmt.Println("TextBox Error:", err)
+
+// SnapshotInto is like Snapshot but copies into the slices of s, reusing
+// their capacity, so redrawing with the same Snapshot doesn't allocate.
+func (r *Row) SnapshotInto(s *Snapshot, n int) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	averages := r.averages
+	if n < 0 {
+		n, averages = r.data.Len(), r.means
 	}
-	return t
+	s.Values = r.data.AppendLast(s.Values[:0], n)
+	s.Labels = r.labels.AppendLast(s.Labels[:0], n)
+	s.Averages = averages.AppendLast(s.Averages[:0], n)
 }
 
-func (r *Row) newBarChart(ctx context.Context) *barchart.BarChart {
-	bc, err := r.createBarGraph(ctx)
-	if err != nil {
-		fmt.Println("BarGraph Error:", err)
+// version returns the number of updates of the row so far.
+func (r *Row) version() uint64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.updates
+}
+
+// AddOverlay adds o to the rows drawn in this row's line chart. It is safe to
+// call while the widgets are running.
+func (r *Row) AddOverlay(o *Row) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.overlayRows = append(r.overlayRows, o)
+}
+
+// appendOverlays appends the overlay rows to dst.
+func (r *Row) appendOverlays(dst []*Row) []*Row {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append(dst, r.overlayRows...)
+}
+
+// clock returns the row's clock, falling back to RealClock.
+func (r *Row) clock() Clock {
+	if r.Clock != nil {
+		return r.Clock
 	}
-	return bc
+	return RealClock
 }
 
-func (r *Row) newSparkLine(ctx context.Context) *sparkline.SparkLine {
-	bc, err := r.createSparkLine(ctx)
-	if err != nil {
-		fmt.Println("Sparkline Error:", err)
+// theme returns the row's theme, falling back to DefaultTheme.
+func (r *Row) theme() *Theme {
+	if r.Theme != nil {
+		return r.Theme
 	}
-	return bc
+	return themes[DefaultTheme]
 }
 
 func (r *Row) ContainerOptions(ctx context.Context, graphType string) []container.Option {
-	var ParBorder int
-	var GraphBorder int
 	var row []container.Option
-	switch r.ID {
-	case 0:
-		GraphBorder = graphOneBorder
-		ParBorder = parOneBorder
-	case 1:
-		GraphBorder = graphOneBorder
-		ParBorder = parOneBorder
-	case 2:
-		GraphBorder = graphTwoBorder
-		ParBorder = parTwoBorder
-	case 3:
-		GraphBorder = graphThreeBorder
-		ParBorder = parThreeBorder
-	case 4:
-		GraphBorder = graphFourBorder
-		ParBorder = parFourBorder
-	case 5:
-		GraphBorder = graphFiveBorder
-		ParBorder = parFiveBorder
-	}
+	theme := r.theme()
+	ParBorder := theme.Border
+	GraphBorder := theme.Border
 	switch graphType {
 	case "line":
 		row = []container.Option{
@@ -196,13 +212,13 @@ func (r *Row) ContainerOptions(ctx context.Context, graphType string) []containe
 					container.Border(linestyle.Round),
 					container.BorderTitle("Statistics"),
 					container.BorderTitleAlignCenter(),
-					container.BorderColor(cell.ColorNumber(ParBorder)),
+					container.BorderColor(color(ParBorder)),
 					container.PlaceWidget(r.Textbox),
 				),
 				container.Right(
 					container.Border(linestyle.Round),
 					container.BorderTitle(r.Label+" - 'q' Quit | 'p' Pause 10s | <- Slow | Resume -> | Scroll to Zoom..."),
-					container.BorderColor(cell.ColorNumber(GraphBorder)),
+					container.BorderColor(color(GraphBorder)),
 					container.PlaceWidget(r.LineChart),
 				),
 				container.SplitPercent(15),
@@ -214,13 +230,13 @@ func (r *Row) ContainerOptions(ctx context.Context, graphType string) []containe
 					container.Border(linestyle.Round),
 					container.BorderTitle("Statistics"),
 					container.BorderTitleAlignCenter(),
-					container.BorderColor(cell.ColorNumber(ParBorder)),
+					container.BorderColor(color(ParBorder)),
 					container.PlaceWidget(r.Textbox),
 				),
 				container.Right(
 					container.Border(linestyle.Round),
 					container.BorderTitle(r.Label+" - 'q' Quit | 'p' Pause 10s | <- Slow | Resume -> | Scroll to Zoom..."),
-					container.BorderColor(cell.ColorNumber(GraphBorder)),
+					container.BorderColor(color(GraphBorder)),
 					container.PlaceWidget(r.BarChart),
 				),
 				container.SplitPercent(15),
@@ -232,13 +248,13 @@ func (r *Row) ContainerOptions(ctx context.Context, graphType string) []containe
 					container.Border(linestyle.Round),
 					container.BorderTitle("Statistics"),
 					container.BorderTitleAlignCenter(),
-					container.BorderColor(cell.ColorNumber(ParBorder)),
+					container.BorderColor(color(ParBorder)),
 					container.PlaceWidget(r.Textbox),
 				),
 				container.Right(
 					container.Border(linestyle.Round),
 					container.BorderTitle(r.Label+" - 'q' Quit | 'p' Pause 10s | <- Slow | Resume -> | Scroll to Zoom..."),
-					container.BorderColor(cell.ColorNumber(GraphBorder)),
+					container.BorderColor(color(GraphBorder)),
 					container.PlaceWidget(r.SparkLine),
 				),
 				container.SplitPercent(15),
@@ -250,13 +266,13 @@ func (r *Row) ContainerOptions(ctx context.Context, graphType string) []containe
 					container.Border(linestyle.Round),
 					container.BorderTitle("Statistics"),
 					container.BorderTitleAlignCenter(),
-					container.BorderColor(cell.ColorNumber(ParBorder)),
+					container.BorderColor(color(ParBorder)),
 					container.PlaceWidget(r.Textbox),
 				),
 				container.Right(
 					container.Border(linestyle.Round),
 					container.BorderTitle(r.Label+" - 'q' Quit | 'p' Pause 10s | <- Slow | Resume -> | Scroll to Zoom..."),
-					container.BorderColor(cell.ColorNumber(GraphBorder)),
+					container.BorderColor(color(GraphBorder)),
 					container.PlaceWidget(r.LineChart),
 				),
 				container.SplitPercent(15),
@@ -266,269 +282,293 @@ func (r *Row) ContainerOptions(ctx context.Context, graphType string) []containe
 }
 
 func (r *Row) newText(ctx context.Context, label string) (*text.Text, error) {
-	var ParTitle int
-	switch r.ID {
-	case 0:
-		ParTitle = parOneTitle
-	case 1:
-		ParTitle = parOneTitle
-	case 2:
-		ParTitle = parTwoTitle
-	case 3:
-		ParTitle = parThreeTitle
-	case 4:
-		ParTitle = parFourTitle
-	case 5:
-		ParTitle = parFiveTitle
-	}
+	theme := r.theme()
+	ParTitle := theme.SeriesColor(r.ID)
+	//the options of the writes, created once
+	titleOpts := text.WriteCellOpts(cell.FgColor(ParTitle))
+	pointerOpts := text.WriteCellOpts(cell.FgColor(color(theme.Pointer)))
+	valueOpts := text.WriteCellOpts(cell.FgColor(color(theme.Value)))
+	statsOpts := text.WriteCellOpts(cell.FgColor(color(theme.Text)))
 
 	t, err := text.New()
+	if err != nil {
+		return nil, err
+	}
 	context := ctx
-	go periodic(context, r.RedrawInterval/2, func() error {
-		defer func() {
-			recover()
-		}()
-		pointer := r.Labels.Last(1)
-		value := r.Data.Last(1)
-		data := prepareStats(r, r.Data)
+	var p statsPanel
+	Periodic(context, r.clock(), r.RedrawInterval/2, func() error {
+		if !p.prepare(r) {
+			return nil
+		}
 		t.Reset()
-		if err := t.Write(fmt.Sprintf("%s", label), text.WriteCellOpts(cell.FgColor(cell.ColorNumber(ParTitle)))); err != nil {
+		if err := t.Write(label, titleOpts); err != nil {
 			return err
 		}
-		if err := t.Write(fmt.Sprintf("\nTime:        %s", pointer), text.WriteCellOpts(cell.FgColor(cell.ColorNumber(parPointer)))); err != nil {
+		if err := t.Write(string(p.pointer), pointerOpts); err != nil {
 			return err
 		}
-		if err := t.Write(fmt.Sprintf("\nValue:       %.2f", value), text.WriteCellOpts(cell.FgColor(cell.ColorNumber(parValue)))); err != nil {
+		if err := t.Write(string(p.value), valueOpts); err != nil {
 			return err
 		}
-		if err := t.Write(fmt.Sprintf("%s", data), text.WriteCellOpts(cell.FgColor(cell.ColorNumber(parText)))); err != nil {
+		if err := t.Write(string(p.stats), statsOpts); err != nil {
 			return err
 		}
 		return nil
 	})
 	return t, err
 }
-func (r *Row) createBarGraph(ctx context.Context) (*barchart.BarChart, error) {
-	var ParTitle int
-	switch r.ID {
-	case 0:
-		ParTitle = parOneTitle
-	case 1:
-		ParTitle = parOneTitle
-	case 2:
-		ParTitle = parTwoTitle
-	case 3:
-		ParTitle = parThreeTitle
-	case 4:
-		ParTitle = parFourTitle
-	case 5:
-		ParTitle = parFiveTitle
+
+// statsPanel holds the text of the statistics panel of a row, prepared in
+// buffers reused across redraws.
+type statsPanel struct {
+	last, all, baseline Snapshot
+	//the lines of the last record and the statistics of all records
+	pointer, value, stats []byte
+	//the statistics are only computed again when the row or its baseline
+	//changed
+	drawn                    bool
+	version, baselineVersion uint64
+}
+
+// prepare fills the panel with the data of r. It returns false when r and its
+// baseline didn't change since the last call.
+func (p *statsPanel) prepare(r *Row) bool {
+	v, bv := r.version(), uint64(0)
+	if r.Baseline != nil {
+		bv = r.Baseline.version()
+	}
+	if p.drawn && v == p.version && bv == p.baselineVersion {
+		return false
 	}
+	p.drawn, p.version, p.baselineVersion = true, v, bv
+	r.SnapshotInto(&p.last, 1)
+	p.pointer = append(p.pointer[:0], "\nTime:        "...)
+	p.value = append(p.value[:0], "\nValue:       "...)
+	//the last record, blank until there is one
+	if len(p.last.Values) > 0 {
+		p.pointer = append(p.pointer, p.last.Labels[0]...)
+		p.value = appendFixed(p.value, p.last.Values[0])
+	}
+	r.SnapshotInto(&p.all, -1)
+	sort.Sort((*float64s)(&p.all.Values))
+	p.baseline.Values = p.baseline.Values[:0]
+	if r.Baseline != nil {
+		r.Baseline.SnapshotInto(&p.baseline, -1)
+		sort.Sort((*float64s)(&p.baseline.Values))
+	}
+	p.stats = appendStats(p.stats[:0], p.all.Values, p.baseline.Values)
+	return true
+}
+
+func (r *Row) createBarGraph(ctx context.Context) (*barchart.BarChart, error) {
+	theme := r.theme()
+	ParTitle := theme.SeriesColor(r.ID)
 	barcolors := make([]cell.Color, 0, 0)
 	valuecolors := make([]cell.Color, 0, 0)
 	for i := 1; i <= 100; i++ {
-		barcolors = append(barcolors, cell.ColorNumber(ParTitle))
+		barcolors = append(barcolors, ParTitle)
 	}
 	for i := 1; i <= 100; i++ {
-		valuecolors = append(valuecolors, cell.ColorBlack)
+		valuecolors = append(valuecolors, color(theme.BarValue))
 	}
-	bc, err := barchart.New(
+	opts := []barchart.Option{
 		barchart.BarColors(barcolors),
 		barchart.ValueColors(valuecolors),
 		barchart.ShowValues(),
 		barchart.BarWidth(3),
-	)
+	}
+	if ParTitle == cell.ColorDefault {
+		//without colors the bars are drawn with a character instead
+		opts = append(opts, barchart.Char('#'))
+	}
+	bc, err := barchart.New(opts...)
 
 	if err != nil {
 		return nil, err
 	}
-	go periodic(ctx, r.RedrawInterval, func() error {
-		defer func() {
-			recover()
-		}()
-		var inputs []float64
-
-		inputs = r.Data.Last(bc.ValueCapacity())
-		values := make([]int, 0)
-		//use averages instead //TODO
-		if r.Average == true {
-			//averages
-			inputs = r.Averages.Last(bc.ValueCapacity())
-		}
-		for _, x := range inputs {
-			values = append(values, round(x))
-		}
-		max := values[0] // assume first value is the smallest
-		for _, value := range values {
-			if value > max {
-				max = value // found another smaller value, replace previous value in max
-			}
+	var bars barValues
+	Periodic(ctx, r.clock(), r.RedrawInterval, func() error {
+		if !bars.prepareBars(r, bc.ValueCapacity()) {
+			return nil
 		}
-
-		return bc.Values(values, max+1)
+		return bc.Values(bars.values, bars.max+1)
 	})
 	return bc, err
 
 }
 
 func (r *Row) createSparkLine(ctx context.Context) (*sparkline.SparkLine, error) {
-	var ParTitle int
-	switch r.ID {
-	case 0:
-		ParTitle = parOneTitle
-	case 1:
-		ParTitle = parOneTitle
-	case 2:
-		ParTitle = parTwoTitle
-	case 3:
-		ParTitle = parThreeTitle
-	case 4:
-		ParTitle = parFourTitle
-	case 5:
-		ParTitle = parFiveTitle
-	}
+	theme := r.theme()
+	ParTitle := theme.SeriesColor(r.ID)
 
 	sl, err := sparkline.New(
-		sparkline.Color(cell.Color(ParTitle)),
+		sparkline.Color(ParTitle),
 	)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	go periodic(ctx, r.RedrawInterval*4, func() error {
-		defer func() {
-			recover()
-		}()
-		var inputs []float64
-
-		inputs = r.Data.Last(1)
-		values := make([]int, 0)
-		//use averages instead //TODO
-		if r.Average == true {
-			//averages
-			inputs = r.Averages.Last(sl.ValueCapacity())
-		}
-		for _, x := range inputs {
-			// display only positive numbers since this is required by sparkline
-			if round(x) > 0 {
-				values = append(values, round(x))
-			}
+	var bars barValues
+	Periodic(ctx, r.clock(), r.RedrawInterval*4, func() error {
+		if !bars.prepareSpark(r, sl.ValueCapacity()) {
+			return nil
 		}
-		max := values[0] // assume first value is the smallest
-		for _, value := range values {
-			if value > max {
-				max = value // found another smaller value, replace previous value in max
-			}
-		}
-		return sl.Add(values)
+		return sl.Add(bars.values)
 	})
 	return sl, err
 
 }
 
+// barValues holds the values of the bars or the sparkline of a row, prepared
+// in buffers reused across redraws. The widgets keep copies.
+type barValues struct {
+	snapshot Snapshot
+	values   []int
+	max      int
+}
+
+// prepareBars fills the values of the last capacity records of r, or of
+// their averages. It returns false when there are none.
+func (b *barValues) prepareBars(r *Row, capacity int) bool {
+	r.SnapshotInto(&b.snapshot, capacity)
+	inputs := b.snapshot.Values
+	//use averages instead //TODO
+	if r.Average == true {
+		//averages
+		inputs = b.snapshot.Averages
+	}
+	return b.round(inputs, false)
+}
+
+// prepareSpark fills the value of the last record of r, or the averages of
+// the last capacity records. It returns false when there are none.
+func (b *barValues) prepareSpark(r *Row, capacity int) bool {
+	r.SnapshotInto(&b.snapshot, 1)
+	inputs := b.snapshot.Values
+	//use averages instead //TODO
+	if r.Average == true {
+		//averages
+		r.SnapshotInto(&b.snapshot, capacity)
+		inputs = b.snapshot.Averages
+	}
+	// display only positive numbers since this is required by sparkline
+	return b.round(inputs, true)
+}
+
+// round sets the values to the rounded inputs, only to the positive ones when
+// positive is set, and max to the largest. It returns false when there are
+// no values.
+func (b *barValues) round(inputs []float64, positive bool) bool {
+	b.values = b.values[:0]
+	for _, x := range inputs {
+		if v := round(x); v > 0 || !positive {
+			b.values = append(b.values, v)
+		}
+	}
+	if len(b.values) == 0 {
+		return false
+	}
+	b.max = b.values[0]
+	for _, value := range b.values {
+		if value > b.max {
+			b.max = value
+		}
+	}
+	return true
+}
+
 func (r *Row) createLineChart(ctx context.Context) (*linechart.LineChart, error) {
 	//set the line color based on the r.ID
-	var GraphLine int
 	var lc *linechart.LineChart
 	var err error
-
-	switch r.ID {
-	case 0:
-		GraphLine = graphLineOne
-	case 1:
-		GraphLine = graphLineOne
-	case 2:
-		GraphLine = graphLineTwo
-	case 3:
-		GraphLine = graphLineThree
-	case 4:
-		GraphLine = graphLineFour
-	case 5:
-		GraphLine = graphLineFive
-	default:
-		GraphLine = 10
-	}
+	theme := r.theme()
+	GraphLine := theme.SeriesColor(r.ID)
 
 	if r.Scroll == true {
 		if r.YAxisAdaptive == true {
 			lc, err = linechart.New(
-				linechart.AxesCellOpts(cell.FgColor(cell.ColorNumber(graphAxes))),
-				linechart.YLabelCellOpts(cell.FgColor(cell.ColorNumber(graphYLabels))),
-				linechart.XLabelCellOpts(cell.FgColor(cell.ColorNumber(graphXLabels))),
+				linechart.AxesCellOpts(cell.FgColor(color(theme.Axes))),
+				linechart.YLabelCellOpts(cell.FgColor(color(theme.YLabels))),
+				linechart.XLabelCellOpts(cell.FgColor(color(theme.XLabels))),
 				linechart.XAxisUnscaled(),
 				linechart.YAxisAdaptive(),
 			)
 		} else {
 			lc, err = linechart.New(
-				linechart.AxesCellOpts(cell.FgColor(cell.ColorNumber(graphAxes))),
-				linechart.YLabelCellOpts(cell.FgColor(cell.ColorNumber(graphYLabels))),
-				linechart.XLabelCellOpts(cell.FgColor(cell.ColorNumber(graphXLabels))),
+				linechart.AxesCellOpts(cell.FgColor(color(theme.Axes))),
+				linechart.YLabelCellOpts(cell.FgColor(color(theme.YLabels))),
+				linechart.XLabelCellOpts(cell.FgColor(color(theme.XLabels))),
 				linechart.XAxisUnscaled(),
 			)
 		}
 	} else {
 		if r.YAxisAdaptive == true {
 			lc, err = linechart.New(
-				linechart.AxesCellOpts(cell.FgColor(cell.ColorNumber(graphAxes))),
-				linechart.YLabelCellOpts(cell.FgColor(cell.ColorNumber(graphYLabels))),
-				linechart.XLabelCellOpts(cell.FgColor(cell.ColorNumber(graphXLabels))),
+				linechart.AxesCellOpts(cell.FgColor(color(theme.Axes))),
+				linechart.YLabelCellOpts(cell.FgColor(color(theme.YLabels))),
+				linechart.XLabelCellOpts(cell.FgColor(color(theme.XLabels))),
 				linechart.YAxisAdaptive(),
 			)
 		} else {
 			lc, err = linechart.New(
-				linechart.AxesCellOpts(cell.FgColor(cell.ColorNumber(graphAxes))),
-				linechart.YLabelCellOpts(cell.FgColor(cell.ColorNumber(graphYLabels))),
-				linechart.XLabelCellOpts(cell.FgColor(cell.ColorNumber(graphXLabels))),
+				linechart.AxesCellOpts(cell.FgColor(color(theme.Axes))),
+				linechart.YLabelCellOpts(cell.FgColor(color(theme.YLabels))),
+				linechart.XLabelCellOpts(cell.FgColor(color(theme.XLabels))),
 			)
 		}
 	}
-	inputs := r.Data.buffer
 	if err != nil {
-		fmt.Println("LineChart Error:", err)
+		return nil, err
 	}
+	inputs := r.Snapshot(-1).Values
 	//step1 = (step1 + 1) % len(inputs)
 	if err := lc.Series("first", inputs,
-		linechart.SeriesCellOpts(cell.FgColor(cell.ColorNumber(GraphLine))),
+		linechart.SeriesCellOpts(cell.FgColor(GraphLine)),
 	); err != nil {
-		fmt.Println("LineChart Error:", err)
+		return nil, err
 	}
 
 	step := 0
-	go periodic(ctx, time.Duration(r.RedrawInterval), func() error {
-		defer func() {
-			recover()
-		}()
-		var inputs []float64
-		var inputLabels []string
-		var averages []float64
-		var graphWidth int
-
+	series := chartSeries{labels: map[int]string{}}
+	//the options of the series, created once, those of the overlays when
+	//they are added
+	firstOpts := []linechart.SeriesOption{
+		linechart.SeriesCellOpts(cell.FgColor(GraphLine)),
+		linechart.SeriesXLabels(series.labels),
+	}
+	baselineOpts := linechart.SeriesCellOpts(cell.FgColor(color(theme.Baseline)))
+	averageOpts := []linechart.SeriesOption{
+		linechart.SeriesCellOpts(cell.FgColor(color(theme.Average))),
+		linechart.SeriesXLabels(series.labels),
+	}
+	var overlayNames []string
+	var overlayOpts []linechart.SeriesOption
+	Periodic(ctx, r.clock(), r.RedrawInterval, func() error {
+		//without scrolling all records are drawn
+		graphWidth := -1
 		if r.Scroll == true {
 			graphWidth = lc.ValueCapacity()
-			inputs = r.Data.Last(graphWidth)
-			inputLabels = r.Labels.Last(graphWidth)
-			averages = r.Averages.Last(graphWidth)
-		} else {
-			//only scroll if we've reached 5000 records
-			inputs = r.DataContainer
-			inputLabels = r.LabelContainer
-			averages = r.AverageContainer
 		}
-		var labelMap = map[int]string{}
-		for i, x := range inputLabels {
-			labelMap[i] = x
-		}
-		if err := lc.Series("first", inputs,
-			linechart.SeriesCellOpts(cell.FgColor(cell.ColorNumber(GraphLine))),
-			linechart.SeriesXLabels(labelMap),
-		); err != nil {
+		series.prepare(r, graphWidth)
+		if err := lc.Series("first", series.snapshot.Values, firstOpts...); err != nil {
 			return err
 		}
+		for i, o := range series.overlays {
+			if i == len(overlayNames) {
+				overlayNames = append(overlayNames, fmt.Sprintf("overlay%d", i))
+				overlayOpts = append(overlayOpts, linechart.SeriesCellOpts(cell.FgColor(o.theme().SeriesColor(o.ID))))
+			}
+			if err := lc.Series(overlayNames[i], series.others[i].Values, overlayOpts[i]); err != nil {
+				return err
+			}
+		}
+		if r.Baseline != nil {
+			if err := lc.Series("baseline", series.baseline.Values, baselineOpts); err != nil {
+				return err
+			}
+		}
 		if r.Average == true {
 			if step%10 == 1 {
-				if err := lc.Series("average", averages,
-					linechart.SeriesCellOpts(cell.FgColor(cell.ColorNumber(lineHigh))),
-					linechart.SeriesXLabels(labelMap),
-				); err != nil {
+				if err := lc.Series("average", series.snapshot.Averages, averageOpts...); err != nil {
 					return err
 				}
 			}
@@ -540,86 +580,171 @@ func (r *Row) createLineChart(ctx context.Context) (*linechart.LineChart, error)
 	return lc, err
 }
 
-func (r *Row) Update(x float64, dataLabel string, averageSeek int) {
-	//add values to ring buffer and data containers
-	r.Data.Add(x)
-	r.Labels.Add(dataLabel)
-	r.DataContainer = append(r.DataContainer, x)
-	r.LabelContainer = append(r.LabelContainer, dataLabel)
-	r.AverageContainer = append(r.AverageContainer, findAverages(r.DataContainer))
+// chartSeries holds the series of a row's line chart, prepared in buffers
+// reused across redraws. The chart keeps copies.
+type chartSeries struct {
+	snapshot, baseline Snapshot
+	//labels maps the positions of the values to their X-Axis labels
+	labels   map[int]string
+	overlays []*Row
+	others   []Snapshot
+}
 
-	//find the average value of all values in Datacontainer
-	avg := findAverages(r.Data.Last(averageSeek))
-	r.Averages.Add(avg)
+// prepare takes the last width records of r, of its overlays and of its
+// baseline, or all of them when width is negative.
+func (c *chartSeries) prepare(r *Row, width int) {
+	r.SnapshotInto(&c.snapshot, width)
+	for i, l := range c.snapshot.Labels {
+		c.labels[i] = l
+	}
+	for i := len(c.snapshot.Labels); i < len(c.labels); i++ {
+		delete(c.labels, i)
+	}
+	c.overlays = r.appendOverlays(c.overlays[:0])
+	for len(c.others) < len(c.overlays) {
+		c.others = append(c.others, Snapshot{})
+	}
+	for i, o := range c.overlays {
+		o.SnapshotInto(&c.others[i], width)
+	}
+	if r.Baseline != nil {
+		r.Baseline.SnapshotInto(&c.baseline, width)
+	}
 }
 
-func findAverages(values []float64) float64 {
+// Update adds a value and its X-Axis label to the row. It is safe to call
+// concurrently with the widgets drawing the row.
+func (r *Row) Update(x float64, dataLabel string, averageSeek int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.updates++
+	//add values to the ring buffers, the oldest are dropped once they are full
+	r.data.AddAt(r.clock().Now(), x)
+	r.labels.Add(dataLabel)
+	r.total += x
+	r.means.Add(r.total / float64(r.updates))
+
+	//find the average of the last averageSeek values
+	n := averageSeek
+	if n > r.data.Len() {
+		n = r.data.Len()
+	}
 	var total float64
-	for _, value := range values {
-		total += value
-	}
-	average := total / float64(len(values))
-	return average
-}
-
-// calulate data stats
-func prepareStats(row *Row, buffer *float64RingBuffer) string {
-	//use only accumulated data instead of ring buffer
-	data := row.DataContainer
-	outliers, _ := stats.QuartileOutliers(data)
-	median, _ := stats.Median(data)
-	mean, _ := stats.Mean(data)
-	min, _ := stats.Min(data)
-	max, _ := stats.Max(data)
-	//variance, _ := stats.Variance(data)
-	//stddev, _ := stats.StandardDeviation(data)
-	var outlierStr string
-	var outliersArr []float64
-
-	//create the outliers slice
-	for _, v := range outliers.Extreme {
-		outliersArr = append(outliersArr, v)
-	}
-	//overwrite the outliers slice with the outliers
-	for _, v := range outliersArr {
-		outliersArr = append(outliersArr, v)
-	}
-
-	//reverse order so largest outliers appear first
-	for i := len(outliersArr)/2 - 1; i >= 0; i-- {
-		opp := len(outliersArr) - 1 - i
-		outliersArr[i], outliersArr[opp] = outliersArr[opp], outliersArr[i]
-	}
-	for i, v := range outliersArr {
-		if i == 0 || i == 1 || i == 2 {
-			s := fmt.Sprintf("%.2f", v)
-			outlierStr = outlierStr + s + "\n             "
-		}
+	for i := r.data.Len() - n; i < r.data.Len(); i++ {
+		total += r.data.At(i)
 	}
-	//count records
+	r.averages.Add(total / float64(n))
+}
+
+// ValueAt returns the newest value added at or before t.
+func (r *Row) ValueAt(t time.Time) (float64, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.data.Lookup(t)
+}
+
+// appendStats appends the statistics of data, compared to the baseline's when
+// there is one, to dst. Both must be sorted, like the statistics panel sorts
+// its snapshots in place.
+func appendStats(dst []byte, data []float64, baseline []float64) []byte {
 	count := len(data)
-	text := fmt.Sprintf("\nCount:       %d\nMin:         %.2f\nMean:        %.2f\nMedian:      %.2f\nMax:         %.2f\nOutliers:    %s",
-		count, min, mean, median, max, outlierStr)
-
-	return text
-}
-
-// periodic executes the provided closure periodically every interval.
-// Exits when the context expires.
-func periodic(ctx context.Context, interval time.Duration, fn func() error) {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-	for {
-		select {
-		case <-ticker.C:
-			if err := fn(); err != nil {
-				// panic(err)
-				return
+	min, mean, median, max := math.NaN(), math.NaN(), math.NaN(), math.NaN()
+	if count > 0 {
+		min, mean, median, max = data[0], sum(data)/float64(count), sortedMedian(data), data[count-1]
+	}
+	dst = strconv.AppendInt(append(dst, "\nCount:       "...), int64(count), 10)
+	dst = appendFixed(append(dst, "\nMin:         "...), min)
+	dst = appendFixed(append(dst, "\nMean:        "...), mean)
+	dst = appendFixed(append(dst, "\nMedian:      "...), median)
+	dst = appendFixed(append(dst, "\nMax:         "...), max)
+	dst = append(dst, "\nOutliers:    "...)
+	if count > 0 {
+		//the 3 largest extreme outliers, outside of 3 interquartile ranges
+		//from the first and third quartile
+		q1, q3 := sortedQuartiles(data)
+		iqr := q3 - q1
+		shown := 0
+		for i := count - 1; i >= 0 && shown < 3; i-- {
+			if v := data[i]; v < q1-3*iqr || v > q3+3*iqr {
+				dst = append(appendFixed(dst, v), "\n             "...)
+				shown++
 			}
-		case <-ctx.Done():
-			return
 		}
 	}
+	if len(baseline) > 0 && count > 0 {
+		baseMean := sum(baseline) / float64(len(baseline))
+		dst = appendPercentChange(append(dst, "\nBaseline:    mean "...), mean, baseMean)
+		dst = appendPercentChange(append(dst, "\n             p99 "...), sortedPercentile(data, 99), sortedPercentile(baseline, 99))
+	}
+	return dst
+}
+
+// float64s sorts values like sort.Float64s. A pointer to the slice of a
+// Snapshot converts to sort.Interface without allocating.
+type float64s []float64
+
+func (s *float64s) Len() int { return len(*s) }
+
+func (s *float64s) Less(i, j int) bool {
+	return (*s)[i] < (*s)[j] || math.IsNaN((*s)[i]) && !math.IsNaN((*s)[j])
+}
+
+func (s *float64s) Swap(i, j int) { (*s)[i], (*s)[j] = (*s)[j], (*s)[i] }
+
+func sum(values []float64) float64 {
+	var total float64
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+
+// sortedMedian returns the median of sorted values, NaN when there are none.
+func sortedMedian(sorted []float64) float64 {
+	l := len(sorted)
+	switch {
+	case l == 0:
+		return math.NaN()
+	case l%2 == 0:
+		return (sorted[l/2-1] + sorted[l/2]) / 2
+	}
+	return sorted[l/2]
+}
+
+// sortedQuartiles returns the first and third quartile of sorted values, the
+// medians of their lower and upper half.
+func sortedQuartiles(sorted []float64) (q1, q3 float64) {
+	l := len(sorted)
+	//the median of an odd number of values belongs to neither half
+	return sortedMedian(sorted[:l/2]), sortedMedian(sorted[(l+1)/2:])
+}
+
+// sortedPercentile returns the nearest rank percentile of sorted values.
+func sortedPercentile(sorted []float64, percent float64) float64 {
+	rank := int(math.Ceil(float64(len(sorted)) * percent / 100))
+	if rank == 0 {
+		return sorted[0]
+	}
+	return sorted[rank-1]
+}
+
+// appendFixed appends v with two decimals, like %.2f.
+func appendFixed(dst []byte, v float64) []byte {
+	return strconv.AppendFloat(dst, v, 'f', 2, 64)
+}
+
+// appendPercentChange appends the change from old to cur as a signed
+// percentage, like %+.0f%%.
+func appendPercentChange(dst []byte, cur, old float64) []byte {
+	if old == 0 {
+		return append(dst, "n/a"...)
+	}
+	change := (cur - old) / math.Abs(old) * 100
+	//AppendFloat only signs negative numbers and +Inf
+	if !math.Signbit(change) && !math.IsInf(change, 1) {
+		dst = append(dst, '+')
+	}
+	return append(strconv.AppendFloat(dst, change, 'f', 0, 64), '%')
 }
 
 // rounding functions used by the bar chart
@@ -0,0 +1,282 @@
+package datadash
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/montanaflynn/stats"
+)
+
+// TestRowConcurrentUpdate updates rows while their widgets draw them, run it
+// with -race.
+func TestRowConcurrentUpdate(t *testing.T) {
+	for _, graphType := range []string{"line", "bar", "spark"} {
+		t.Run(graphType, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			r := NewRow("latency", BufferSize(100), SeriesID(1), Scroll(true), AverageLine(true, 50))
+			r.Baseline = NewRow("latency (baseline)", BufferSize(100), SeriesID(1), Scroll(true))
+			if err := r.InitWidgets(ctx, graphType, r.Label, time.Millisecond, time.Millisecond); err != nil {
+				t.Fatal(err)
+			}
+
+			var wg sync.WaitGroup
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 500; i++ {
+					r.Update(float64(i), fmt.Sprint(i), 50)
+					r.Baseline.Update(float64(i/2), fmt.Sprint(i), 50)
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 20; i++ {
+					r.AddOverlay(NewRow("overlay", BufferSize(100), SeriesID(i+2), Scroll(true)))
+					r.Snapshot(10)
+					time.Sleep(time.Millisecond)
+				}
+			}()
+			wg.Wait()
+
+			s := r.Snapshot(-1)
+			if len(s.Values) != 100 || len(s.Labels) != 100 || len(s.Averages) != 100 {
+				t.Fatalf("Snapshot(-1) returned %d values, %d labels and %d averages, want the 100 the buffer holds", len(s.Values), len(s.Labels), len(s.Averages))
+			}
+			if last := r.Snapshot(1); len(last.Values) != 1 || last.Values[0] != 499 || last.Labels[0] != "499" {
+				t.Fatalf("Snapshot(1) = %v %v, want [499] [499]", last.Values, last.Labels)
+			}
+		})
+	}
+}
+
+// TestRowBuffer checks that a row holds its last values only, along with
+// their moving averages and the averages of all values.
+func TestRowBuffer(t *testing.T) {
+	r := NewRow("latency", BufferSize(3))
+	for i := 1; i <= 5; i++ {
+		r.Update(float64(i), strconv.Itoa(i), 2)
+	}
+	all := r.Snapshot(-1)
+	if fmt.Sprint(all.Values, all.Labels, all.Averages) != "[3 4 5] [3 4 5] [2 2.5 3]" {
+		t.Errorf("Snapshot(-1) = %v %v %v, want [3 4 5] [3 4 5] [2 2.5 3]", all.Values, all.Labels, all.Averages)
+	}
+	last := r.Snapshot(2)
+	if fmt.Sprint(last.Values, last.Labels, last.Averages) != "[4 5] [4 5] [3.5 4.5]" {
+		t.Errorf("Snapshot(2) = %v %v %v, want [4 5] [4 5] [3.5 4.5]", last.Values, last.Labels, last.Averages)
+	}
+}
+
+// statsText returns the statistics of data like the statistics panel, which
+// sorts its snapshots first.
+func statsText(data, baseline []float64) string {
+	data = append([]float64(nil), data...)
+	baseline = append([]float64(nil), baseline...)
+	sort.Float64s(data)
+	sort.Float64s(baseline)
+	return string(appendStats(nil, data, baseline))
+}
+
+func TestStats(t *testing.T) {
+	for _, tc := range []struct {
+		data []float64
+		want string
+	}{
+		{
+			data: nil,
+			want: "\nCount:       0\nMin:         NaN\nMean:        NaN\nMedian:      NaN\nMax:         NaN\nOutliers:    ",
+		},
+		{
+			data: []float64{4},
+			want: "\nCount:       1\nMin:         4.00\nMean:        4.00\nMedian:      4.00\nMax:         4.00\nOutliers:    ",
+		},
+		{
+			//the largest extreme outliers come first
+			data: []float64{200, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 100, -80},
+			want: "\nCount:       13\nMin:         -80.00\nMean:        21.15\nMedian:      6.00\nMax:         200.00\nOutliers:    200.00\n             100.00\n             -80.00\n             ",
+		},
+		{
+			//an outlier is shown once
+			data: []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 100},
+			want: "\nCount:       11\nMin:         1.00\nMean:        14.09\nMedian:      6.00\nMax:         100.00\nOutliers:    100.00\n             ",
+		},
+	} {
+		if got := statsText(tc.data, nil); got != tc.want {
+			t.Errorf("statsText(%v) = %q, want %q", tc.data, got, tc.want)
+		}
+	}
+}
+
+// TestSortedStats compares the statistics of sorted values with those of the
+// stats package, which prepareStats used before.
+func TestSortedStats(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for n := 0; n < 50; n++ {
+		data := make([]float64, n)
+		for i := range data {
+			//few distinct values, so there are duplicates
+			data[i] = float64(rnd.Intn(20) - 5)
+		}
+		if n%7 == 0 && n > 0 {
+			data[0] = 1000
+		}
+		sorted := append([]float64(nil), data...)
+		sort.Float64s(sorted)
+
+		median, _ := stats.Median(data)
+		if got := sortedMedian(sorted); !sameValue(got, median) {
+			t.Errorf("sortedMedian(%v) = %v, stats.Median() = %v", sorted, got, median)
+		}
+		if n == 0 {
+			continue
+		}
+		quartiles, _ := stats.Quartile(data)
+		if q1, q3 := sortedQuartiles(sorted); !sameValue(q1, quartiles.Q1) || !sameValue(q3, quartiles.Q3) {
+			t.Errorf("sortedQuartiles(%v) = %v %v, stats.Quartile() = %v %v", sorted, q1, q3, quartiles.Q1, quartiles.Q3)
+		}
+		for _, percent := range []float64{1, 50, 99, 100} {
+			want, _ := stats.PercentileNearestRank(data, percent)
+			if got := sortedPercentile(sorted, percent); got != want {
+				t.Errorf("sortedPercentile(%v, %v) = %v, stats.PercentileNearestRank() = %v", sorted, percent, got, want)
+			}
+		}
+		//the 3 largest extreme outliers, the old panel repeated them when
+		//there were fewer
+		outliers, _ := stats.QuartileOutliers(data)
+		var want strings.Builder
+		for i := len(outliers.Extreme) - 1; i >= 0 && i >= len(outliers.Extreme)-3; i-- {
+			fmt.Fprintf(&want, "%.2f\n             ", outliers.Extreme[i])
+		}
+		if got := statsText(data, nil); !strings.HasSuffix(got, "Outliers:    "+want.String()) {
+			t.Errorf("statsText(%v) = %q, want the outliers %q", sorted, got, want.String())
+		}
+	}
+}
+
+func TestStatsBaseline(t *testing.T) {
+	for _, tc := range []struct {
+		data, baseline []float64
+		want           string
+	}{
+		{
+			data:     []float64{11, 12, 13, 20},
+			baseline: []float64{10, 10, 12, 16},
+			want:     "\nBaseline:    mean +17%\n             p99 +25%",
+		},
+		{
+			data:     []float64{8, 10},
+			baseline: []float64{10, 10},
+			want:     "\nBaseline:    mean -10%\n             p99 +0%",
+		},
+		{
+			data:     []float64{-2, -1},
+			baseline: []float64{-4, -2},
+			want:     "\nBaseline:    mean +50%\n             p99 +50%",
+		},
+		{
+			data:     []float64{1, 2},
+			baseline: []float64{-1, 1},
+			want:     "\nBaseline:    mean n/a\n             p99 +100%",
+		},
+		{
+			data:     []float64{1, 2},
+			baseline: []float64{0, 0},
+			want:     "\nBaseline:    mean n/a\n             p99 n/a",
+		},
+	} {
+		text := statsText(tc.data, tc.baseline)
+		if !strings.HasSuffix(text, tc.want) {
+			t.Errorf("statsText(%v, %v) = %q, want the suffix %q", tc.data, tc.baseline, text, tc.want)
+		}
+	}
+	if text := statsText([]float64{1, 2}, nil); strings.Contains(text, "Baseline") {
+		t.Errorf("statsText() without baseline = %q", text)
+	}
+}
+
+// TestFormat compares the number formatting of the statistics with fmt.
+func TestFormat(t *testing.T) {
+	for _, v := range []float64{0, math.Copysign(0, -1), -0.001, 1.005, -2.5, 12345.678, 1e21, math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if got, want := string(appendFixed(nil, v)), fmt.Sprintf("%.2f", v); got != want {
+			t.Errorf("appendFixed(%v) = %q, want %q", v, got, want)
+		}
+		if got, want := string(appendPercentChange(nil, v, -4)), fmt.Sprintf("%+.0f%%", (v+4)/4*100); got != want {
+			t.Errorf("appendPercentChange(%v, -4) = %q, want %q", v, got, want)
+		}
+	}
+}
+
+// redrawAllocs returns the allocations of an update of r followed by the
+// preparation of the data of all its widgets, which have buffers of
+// capacity values.
+func redrawAllocs(r *Row, capacity int) float64 {
+	var panel statsPanel
+	var bars barValues
+	series := chartSeries{labels: map[int]string{}}
+	i := 0
+	return testing.AllocsPerRun(100, func() {
+		r.Update(float64(i%100), strconv.Itoa(i%100), 50)
+		i++
+		panel.prepare(r)
+		bars.prepareBars(r, capacity)
+		bars.prepareSpark(r, capacity)
+		series.prepare(r, capacity)
+		series.prepare(r, -1)
+	})
+}
+
+// TestRowRedrawAllocs checks that the redraws of a full row prepare the data
+// of the widgets without allocating, only the widgets copy it.
+func TestRowRedrawAllocs(t *testing.T) {
+	r := NewRow("latency", BufferSize(200), SeriesID(1), AverageLine(true, 50))
+	r.Baseline = NewRow("latency (baseline)", BufferSize(200))
+	r.AddOverlay(NewRow("overlay", BufferSize(200), SeriesID(2)))
+	for i := 0; i < 300; i++ {
+		r.Update(float64(i%100), strconv.Itoa(i%100), 50)
+		r.Baseline.Update(float64(i%50), strconv.Itoa(i%50), 50)
+		r.overlayRows[0].Update(float64(i%20), strconv.Itoa(i%20), 50)
+	}
+	if allocs := redrawAllocs(r, 100); allocs > 0 {
+		t.Errorf("a redraw allocates %v times", allocs)
+	}
+}
+
+// BenchmarkRowRedraw updates a row of 10000 records and lets all of its
+// widgets redraw once, the text widget computing the statistics. It fails
+// when preparing the data of the widgets allocates, the allocations reported
+// are those of the termdash widgets copying it.
+func BenchmarkRowRedraw(b *testing.B) {
+	for _, graphType := range []string{"line", "bar", "spark"} {
+		b.Run(graphType, func(b *testing.B) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			clock := NewFakeClock(time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC))
+			r := NewRow("latency", BufferSize(1000), SeriesID(1), Scroll(true), AverageLine(true, 50), UseClock(clock))
+			if err := r.InitWidgets(ctx, graphType, r.Label, 4*time.Millisecond, 4*time.Millisecond); err != nil {
+				b.Fatal(err)
+			}
+			for i := 0; i < 10000; i++ {
+				r.Update(float64(i%100), "10:00:00", 50)
+			}
+			//the widgets only redraw when the clock advances
+			if allocs := redrawAllocs(r, 1000); allocs > 0 {
+				b.Fatalf("a redraw allocates %v times before the widgets copy the data", allocs)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				r.Update(float64(i%100), "10:00:00", 50)
+				//the slowest widget, the sparkline, redraws every 4 intervals
+				clock.Advance(16 * time.Millisecond)
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package datadash
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sink receives every processed record, after derivation, counter transforms
+// and aggregation: its X-Axis label and one value per column, NaN where the
+// record has no value. Columns may grow between records.
+type Sink interface {
+	Write(label string, columns []string, values []float64) error
+	Close() error
+}
+
+// NewSink returns the sink described by spec: csv:FILE, jsonl:FILE or an
+// http:// URL records are posted to.
+func NewSink(spec string) (Sink, error) {
+	switch {
+	case strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://"):
+		return NewHTTPSink(spec), nil
+	case strings.HasPrefix(spec, "csv:"):
+		return NewCSVSink(strings.TrimPrefix(spec, "csv:"))
+	case strings.HasPrefix(spec, "jsonl:"):
+		return NewJSONLinesSink(strings.TrimPrefix(spec, "jsonl:"))
+	}
+	return nil, fmt.Errorf("sink %q: must be csv:FILE, jsonl:FILE or an http:// URL", spec)
+}
+
+// CSVSink writes records as delimited data to a file, starting with a header
+// line. A new header line is written when columns are added.
+type CSVSink struct {
+	file    *os.File
+	buf     *bufio.Writer
+	writer  *csv.Writer
+	columns int
+}
+
+// NewCSVSink creates the file name, tab separated like the input of datadash.
+func NewCSVSink(name string) (*CSVSink, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	buf := bufio.NewWriter(f)
+	w := csv.NewWriter(buf)
+	w.Comma = '\t'
+	return &CSVSink{file: f, buf: buf, writer: w, columns: -1}, nil
+}
+
+// Write writes the record, missing values are left empty.
+func (s *CSVSink) Write(label string, columns []string, values []float64) error {
+	if len(columns) != s.columns {
+		s.columns = len(columns)
+		if err := s.writer.Write(append([]string{LabelColumn}, columns...)); err != nil {
+			return err
+		}
+	}
+	record := make([]string, len(values)+1)
+	record[0] = label
+	for i, v := range values {
+		if !math.IsNaN(v) {
+			record[i+1] = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+	}
+	if err := s.writer.Write(record); err != nil {
+		return err
+	}
+	//keep the file current for readers tailing it
+	s.writer.Flush()
+	if err := s.writer.Error(); err != nil {
+		return err
+	}
+	return s.buf.Flush()
+}
+
+// Close closes the file.
+func (s *CSVSink) Close() error {
+	s.writer.Flush()
+	s.buf.Flush()
+	return s.file.Close()
+}
+
+// jsonRecord is the JSON form of a record written by JSONLinesSink and
+// HTTPSink. Missing values are left out.
+type jsonRecord struct {
+	Label  string             `json:"label"`
+	Values map[string]float64 `json:"values"`
+}
+
+func newJSONRecord(label string, columns []string, values []float64) jsonRecord {
+	rec := jsonRecord{Label: label, Values: make(map[string]float64, len(values))}
+	for i, v := range values {
+		if i < len(columns) && !math.IsNaN(v) && !math.IsInf(v, 0) {
+			rec.Values[columns[i]] = v
+		}
+	}
+	return rec
+}
+
+// JSONLinesSink writes records to a file as one JSON object per line:
+// {"label":"10:00:01","values":{"latency":5}}.
+type JSONLinesSink struct {
+	w io.WriteCloser
+}
+
+// NewJSONLinesSink creates the file name.
+func NewJSONLinesSink(name string) (*JSONLinesSink, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONLinesSink{w: f}, nil
+}
+
+// Write writes the record.
+func (s *JSONLinesSink) Write(label string, columns []string, values []float64) error {
+	return json.NewEncoder(s.w).Encode(newJSONRecord(label, columns, values))
+}
+
+// Close closes the file.
+func (s *JSONLinesSink) Close() error {
+	return s.w.Close()
+}
+
+// HTTPSink posts every record as a JSON object, like JSONLinesSink writes
+// them, to a URL. Records are posted in the background so a slow endpoint
+// doesn't hold up the dashboard; records arriving while the queue is full are
+// dropped.
+type HTTPSink struct {
+	URL    string
+	Client *http.Client
+	queue  chan []byte
+	done   chan struct{}
+
+	mu      sync.Mutex
+	lastErr error
+}
+
+// NewHTTPSink returns a sink posting to url.
+func NewHTTPSink(url string) *HTTPSink {
+	s := &HTTPSink{
+		URL:    url,
+		Client: &http.Client{Timeout: 5 * time.Second},
+		queue:  make(chan []byte, 100),
+		done:   make(chan struct{}),
+	}
+	go s.post()
+	return s
+}
+
+// Write queues the record. It returns the error of a previous post, if any.
+func (s *HTTPSink) Write(label string, columns []string, values []float64) error {
+	body, err := json.Marshal(newJSONRecord(label, columns, values))
+	if err != nil {
+		return err
+	}
+	select {
+	case s.queue <- body:
+	default:
+		return fmt.Errorf("post %s: queue full, record dropped", s.URL)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err, s.lastErr = s.lastErr, nil
+	return err
+}
+
+// Close posts the queued records and stops.
+func (s *HTTPSink) Close() error {
+	close(s.queue)
+	<-s.done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastErr
+}
+
+func (s *HTTPSink) post() {
+	defer close(s.done)
+	for body := range s.queue {
+		err := s.send(body)
+		if err != nil {
+			s.mu.Lock()
+			s.lastErr = err
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *HTTPSink) send(body []byte) error {
+	resp, err := s.Client.Post(s.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("post %s: %s", s.URL, resp.Status)
+	}
+	return nil
+}
+
+// Tee writes records to several sinks. A failing sink doesn't stop the
+// others, its errors are counted.
+type Tee struct {
+	Sinks []Sink
+
+	mu      sync.Mutex
+	records int
+	errors  int
+	lastErr error
+}
+
+// NewTee returns a Tee writing to sinks.
+func NewTee(sinks ...Sink) *Tee {
+	return &Tee{Sinks: sinks}
+}
+
+// Write writes the record to every sink and returns the first error.
+func (t *Tee) Write(label string, columns []string, values []float64) error {
+	var first error
+	failed := 0
+	for _, s := range t.Sinks {
+		if err := s.Write(label, columns, values); err != nil {
+			failed++
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.records++
+	t.errors += failed
+	if first != nil {
+		t.lastErr = first
+	}
+	return first
+}
+
+// Close closes every sink and returns the first error.
+func (t *Tee) Close() error {
+	var first error
+	for _, s := range t.Sinks {
+		if err := s.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Status returns the number of records written, of failed writes and the
+// last error. It is safe to call concurrently with Write.
+func (t *Tee) Status() (records, errors int, lastErr error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.records, t.errors, t.lastErr
+}
@@ -0,0 +1,70 @@
+package datadash
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileSinks(t *testing.T) {
+	dir := t.TempDir()
+	csvName, jsonName := filepath.Join(dir, "out.tsv"), filepath.Join(dir, "out.jsonl")
+	tee := NewTee()
+	for _, spec := range []string{"csv:" + csvName, "jsonl:" + jsonName} {
+		s, err := NewSink(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tee.Sinks = append(tee.Sinks, s)
+	}
+	tee.Write("10:00:01", []string{"latency"}, []float64{5})
+	tee.Write("10:00:02", []string{"latency", "errors"}, []float64{math.NaN(), 2})
+	if err := tee.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if records, errors, _ := tee.Status(); records != 2 || errors != 0 {
+		t.Errorf("Status() = %d records, %d errors, want 2, 0", records, errors)
+	}
+
+	want := map[string]string{
+		csvName:  "x\tlatency\n10:00:01\t5\nx\tlatency\terrors\n10:00:02\t\t2\n",
+		jsonName: `{"label":"10:00:01","values":{"latency":5}}` + "\n" + `{"label":"10:00:02","values":{"errors":2}}` + "\n",
+	}
+	for name, w := range want {
+		got, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != w {
+			t.Errorf("%s = %q, want %q", filepath.Base(name), got, w)
+		}
+	}
+}
+
+func TestHTTPSink(t *testing.T) {
+	bodies := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- string(body)
+	}))
+	defer srv.Close()
+
+	s, err := NewSink(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write("10:00:01", []string{"latency"}, []float64{5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.TrimSpace(<-bodies), `{"label":"10:00:01","values":{"latency":5}}`; got != want {
+		t.Errorf("posted %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,403 @@
+package datadash
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Dialect describes delimited data: how its fields are separated and whether
+// it starts with a header line.
+type Dialect struct {
+	// Delimiter separates the fields unless Whitespace is set.
+	Delimiter rune
+	// Whitespace splits the fields on runs of spaces and tabs.
+	Whitespace bool
+	// Header is set when the first line names the columns.
+	Header bool
+}
+
+// HeaderMode tells whether delimited data starts with a header line.
+type HeaderMode int
+
+// header modes, HeaderAuto detects the header from the first lines
+const (
+	HeaderAuto HeaderMode = iota
+	HeaderPresent
+	HeaderAbsent
+)
+
+// ParseHeaderMode parses one of "auto", "yes" or "no".
+func ParseHeaderMode(s string) (HeaderMode, error) {
+	switch strings.ToLower(s) {
+	case "auto":
+		return HeaderAuto, nil
+	case "yes":
+		return HeaderPresent, nil
+	case "no":
+		return HeaderAbsent, nil
+	}
+	return HeaderAuto, fmt.Errorf("unknown header mode %q, must be one of: auto, yes, no", s)
+}
+
+// delimiters are the delimiters recognized by SniffDialect, in order of
+// preference
+var delimiters = []rune{'\t', ',', ';', '|'}
+
+// sampleLines is the maximum number of lines read by DetectDialect
+const sampleLines = 10
+
+// SniffDialect guesses the dialect of delimited data from its first lines.
+// The delimiter is the one splitting every line into the same number of
+// fields, the most of them when several do, or runs of whitespace when none
+// does. The delimiter of known is used instead of guessing it when it isn't
+// 0, and so are runs of whitespace when known.Whitespace is set. The first
+// line is a header when it has text where the second line has numbers, or
+// for aligned columns when it isn't a record itself.
+func SniffDialect(lines []string, known Dialect) Dialect {
+	lines = nonBlank(lines)
+	d := Dialect{Delimiter: known.Delimiter, Whitespace: known.Whitespace}
+	if d.Whitespace {
+		d.Delimiter = ' '
+	} else if d.Delimiter == 0 {
+		d = sniffDelimiter(lines)
+	}
+	if len(lines) == 0 {
+		d.Header = true
+		return d
+	}
+	first := d.split(lines[0])
+	if d.Whitespace {
+		//the header may follow banner lines, like the one of vmstat
+		d.Header = !isRecord(first)
+		return d
+	}
+	var second []string
+	if len(lines) > 1 {
+		second = d.split(lines[1])
+	}
+	for i, f := range first {
+		if strings.TrimSpace(f) == "" || isNumber(f) {
+			continue
+		}
+		if second == nil || i < len(second) && isNumber(second[i]) {
+			d.Header = true
+			break
+		}
+	}
+	return d
+}
+
+// nonBlank returns the lines which aren't blank.
+func nonBlank(lines []string) []string {
+	kept := make([]string, 0, len(lines))
+	for _, l := range lines {
+		if strings.TrimSpace(l) != "" {
+			kept = append(kept, l)
+		}
+	}
+	return kept
+}
+
+// sniffDelimiter returns the dialect splitting lines into the most fields.
+func sniffDelimiter(lines []string) Dialect {
+	best, fields := Dialect{Delimiter: delimiters[0]}, 1
+	for _, c := range delimiters {
+		if n := sameFields(lines, Dialect{Delimiter: c}); n > fields {
+			best, fields = Dialect{Delimiter: c}, n
+		}
+	}
+	if fields > 1 {
+		return best
+	}
+	//aligned columns may have a header with spaces in the names and banner
+	//lines, only the records split into the same number of fields
+	ws := Dialect{Delimiter: ' ', Whitespace: true}
+	var data []string
+	for _, l := range lines {
+		if isRecord(ws.split(l)) {
+			data = append(data, l)
+		}
+	}
+	if len(data) == 0 && len(lines) > 1 {
+		data = lines[1:]
+	}
+	if sameFields(data, ws) > 1 {
+		return ws
+	}
+	return best
+}
+
+// sameFields returns the number of fields of every line, or 0 when it
+// differs between lines.
+func sameFields(lines []string, d Dialect) int {
+	n := 0
+	for i, l := range lines {
+		fields := len(d.split(l))
+		if i > 0 && fields != n {
+			return 0
+		}
+		n = fields
+	}
+	return n
+}
+
+// split returns the fields of a line.
+func (d Dialect) split(line string) []string {
+	line = strings.TrimRight(line, "\r\n")
+	if d.Whitespace {
+		return strings.Fields(line)
+	}
+	r := csv.NewReader(strings.NewReader(line))
+	r.Comma = d.Delimiter
+	r.LazyQuotes = true
+	fields, err := r.Read()
+	if err != nil {
+		return []string{line}
+	}
+	return fields
+}
+
+// isNumber tells whether a field holds a value or a timestamp rather than a
+// name.
+func isNumber(s string) bool {
+	s = strings.TrimSpace(s)
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return true
+	}
+	_, ok := ParseTimestamp(s)
+	return ok
+}
+
+// isRecord tells whether at least half of the fields of a line are numbers or
+// timestamps, which makes it a record rather than a header.
+func isRecord(fields []string) bool {
+	numbers := 0
+	for _, f := range fields {
+		if isNumber(f) {
+			numbers++
+		}
+	}
+	return len(fields) > 0 && 2*numbers >= len(fields)
+}
+
+// DetectDialect reads the first lines of r to guess its dialect with
+// SniffDialect, using what is known of it, and returns a reader replaying
+// them before the rest of r. It waits for two lines, and reads up to ten as
+// long as they are available without waiting, so slow streams aren't held
+// up.
+func DetectDialect(r io.Reader, known Dialect) (Dialect, io.Reader, error) {
+	br := bufio.NewReader(r)
+	var sample bytes.Buffer
+	var lines []string
+	for len(lines) < sampleLines {
+		if len(lines) >= 2 {
+			buffered, _ := br.Peek(br.Buffered())
+			if bytes.IndexByte(buffered, '\n') < 0 {
+				break
+			}
+		}
+		line, err := br.ReadString('\n')
+		sample.WriteString(line)
+		if line != "" {
+			lines = append(lines, line)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Dialect{}, nil, err
+		}
+	}
+	return SniffDialect(lines, known), io.MultiReader(&sample, br), nil
+}
+
+// columnGaps separate the names of a header of aligned columns
+var columnGaps = regexp.MustCompile(`\s{2,}|\t`)
+
+// lineKey identifies a header or banner line by its fields which aren't
+// numbers, so repeated headers starting with the time, like the ones of sar,
+// are recognized.
+func lineKey(fields []string) string {
+	names := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if !isNumber(f) {
+			names = append(names, f)
+		}
+	}
+	return strings.Join(names, " ")
+}
+
+// CSVReader is a ColumnReader for delimited data, starting with a header
+// line unless its dialect says otherwise. Aligned columns split on runs of
+// whitespace may start with banner lines before the header, repeats of these
+// lines and of the header are skipped, as printed periodically by tools like
+// vmstat or sar.
+type CSVReader struct {
+	reader *csv.Reader
+	//lines are split on whitespace instead of by reader
+	lines   *bufio.Reader
+	line    int
+	raw     string
+	skip    map[string]bool
+	dialect Dialect
+	header  []string
+	//pending is the first record when it was read to name the columns
+	pending []string
+	//unlabeled records get an empty label, replaced by the arrival time
+	unlabeled bool
+}
+
+// NewCSVReader reads the header line from r. Records with a different number
+// of fields than the header are accepted.
+func NewCSVReader(r io.Reader, comma rune) (*CSVReader, error) {
+	return NewDialectReader(r, Dialect{Delimiter: comma, Header: true})
+}
+
+// NewDialectReader reads delimited data of dialect d from r. Without a header
+// line the columns are named col1..colN after the fields of the first record.
+// The names of a header are trimmed. Records with a different number of
+// fields than the header are accepted. Aligned columns starting with a column
+// of plain numbers, like the ones of vmstat, have no label column: an empty
+// label is added to their records.
+func NewDialectReader(r io.Reader, d Dialect) (*CSVReader, error) {
+	cr := &CSVReader{dialect: d}
+	if d.Whitespace {
+		cr.lines = bufio.NewReader(r)
+		cr.skip = map[string]bool{}
+	} else {
+		cr.reader = csv.NewReader(r)
+		cr.reader.Comma = d.Delimiter
+		cr.reader.FieldsPerRecord = -1
+	}
+	first, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	if !d.Header {
+		cr.header = make([]string, len(first))
+		for i := range first {
+			cr.header[i] = fmt.Sprintf("col%d", i+1)
+		}
+		cr.pending = first
+	} else {
+		cr.header = first
+		if d.Whitespace {
+			if cr.header, err = cr.readHeader(); err != nil {
+				return nil, err
+			}
+		}
+		for i, name := range cr.header {
+			cr.header[i] = strings.TrimSpace(name)
+		}
+	}
+	if d.Whitespace && cr.pending != nil && cr.header[0] != LabelColumn {
+		if _, err := strconv.ParseFloat(cr.pending[0], 64); err == nil {
+			cr.unlabeled = true
+			cr.header = append([]string{LabelColumn}, cr.header...)
+		}
+	}
+	return cr, nil
+}
+
+// readHeader reads the lines of aligned columns up to the first record, the
+// first of them was read last. The header is the last line before the record,
+// the others are banners. Names may contain single spaces when the header
+// splits into as many columns as the record on runs of two or more spaces,
+// and not on every space.
+func (r *CSVReader) readHeader() ([]string, error) {
+	header, line := r.header, r.raw
+	r.skip[lineKey(header)] = true
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return header, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if isRecord(record) {
+			r.pending = record
+			break
+		}
+		header, line = record, r.raw
+		r.skip[lineKey(header)] = true
+	}
+	if len(header) != len(r.pending) {
+		if names := columnGaps.Split(strings.TrimSpace(line), -1); len(names) == len(r.pending) {
+			return names, nil
+		}
+	}
+	return header, nil
+}
+
+// Read returns the next record.
+func (r *CSVReader) Read() ([]string, error) {
+	record, err := r.next()
+	if err == nil && r.unlabeled {
+		record = append([]string{""}, record...)
+	}
+	return record, err
+}
+
+func (r *CSVReader) next() ([]string, error) {
+	if r.pending != nil {
+		record := r.pending
+		r.pending = nil
+		return record, nil
+	}
+	if r.reader != nil {
+		return r.reader.Read()
+	}
+	for {
+		line, err := r.lines.ReadString('\n')
+		if line == "" && err != nil {
+			return nil, err
+		}
+		r.line++
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if key := lineKey(fields); key != "" && r.skip[key] {
+			continue
+		}
+		r.raw = line
+		return fields, nil
+	}
+}
+
+// Dialect returns the dialect of the data.
+func (r *CSVReader) Dialect() Dialect {
+	return r.dialect
+}
+
+// Line returns the input line of the start of the last record read.
+func (r *CSVReader) Line() int {
+	if r.reader == nil {
+		return r.line
+	}
+	line, _ := r.reader.FieldPos(0)
+	return line
+}
+
+// Header returns the header line, or the generated column names.
+func (r *CSVReader) Header() []string {
+	return r.header
+}
+
+// Columns returns the header without the label column.
+func (r *CSVReader) Columns() []string {
+	if len(r.header) == 0 {
+		return nil
+	}
+	return r.header[1:]
+}
@@ -0,0 +1,177 @@
+package datadash
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSniffDialect(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		lines string
+		want  Dialect
+	}{
+		{"tab", "x\tlatency\n00:00\t5\n", Dialect{Delimiter: '\t', Header: true}},
+		{"comma", "time,latency,errors\n10:00:01,5,1\n10:00:02,6,0\n", Dialect{Delimiter: ',', Header: true}},
+		{"quoted comma", "name,\"a, b\"\nweb1,5\n", Dialect{Delimiter: ',', Header: true}},
+		{"semicolon", "x;a;b\n1;2;3\n", Dialect{Delimiter: ';', Header: true}},
+		{"pipe", "1|2|3\n4|5|6\n", Dialect{Delimiter: '|'}},
+		{"whitespace", "x    latency   errors\n00:00   5  1\n00:01  6      0\n", Dialect{Delimiter: ' ', Whitespace: true, Header: true}},
+		{"headerless", "10:00:01,5\n10:00:02,6\n", Dialect{Delimiter: ','}},
+		{"labels", "web1,5\nweb2,6\n", Dialect{Delimiter: ','}},
+		{"streaming", "50\n60\n70\n", Dialect{Delimiter: '\t'}},
+		{"streaming header", "x\n60\n", Dialect{Delimiter: '\t', Header: true}},
+		{"blank lines", "\na,b\n\n1,2\n", Dialect{Delimiter: ',', Header: true}},
+	} {
+		lines := strings.SplitAfter(tc.lines, "\n")
+		if got := SniffDialect(lines, Dialect{}); got != tc.want {
+			t.Errorf("%s: SniffDialect() = %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+	if got, want := SniffDialect([]string{"a,b;c\n", "1,2;3\n"}, Dialect{Delimiter: ';'}), (Dialect{Delimiter: ';', Header: true}); got != want {
+		t.Errorf("SniffDialect() with a delimiter = %+v, want %+v", got, want)
+	}
+}
+
+func TestDetectHeaderless(t *testing.T) {
+	r, err := NewFormatReader("csv", strings.NewReader("10:00:01,5,1\n10:00:02,6,0\n"), FormatOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr := r.(*CSVReader)
+	if got, want := cr.Header(), []string{"col1", "col2", "col3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Header() = %q, want %q", got, want)
+	}
+	for i, want := range [][]string{{"10:00:01", "5", "1"}, {"10:00:02", "6", "0"}} {
+		rec, err := r.Read()
+		if err != nil || !reflect.DeepEqual(rec, want) {
+			t.Fatalf("Read() = %q, %v, want %q", rec, err, want)
+		}
+		if cr.Line() != i+1 {
+			t.Errorf("Line() = %d, want %d", cr.Line(), i+1)
+		}
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("Read() at the end = %v, want EOF", err)
+	}
+}
+
+// TestDetectAligned reads a sample file of columns aligned with spaces, whose
+// names contain single spaces.
+func TestDetectAligned(t *testing.T) {
+	f, err := os.Open("tools/sampledata/5col-data-today")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := NewFormatReader("csv", f, FormatOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.Columns(), []string{"Lock Failures", "Case Closed Exceptions", "Timeouts", "Responses"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Columns() = %q, want %q", got, want)
+	}
+	rec, err := r.Read()
+	if want := []string{"00:00", "10", "8", "1", "167"}; err != nil || !reflect.DeepEqual(rec, want) {
+		t.Errorf("Read() = %q, %v, want %q", rec, err, want)
+	}
+}
+
+// TestDetectStream checks detection doesn't wait for more than two lines of
+// a slow stream.
+func TestDetectStream(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	go pw.Write([]byte("x,latency\n10:00:01,5\n"))
+	d, replay, err := DetectDialect(pr, Dialect{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Dialect{Delimiter: ',', Header: true}); d != want {
+		t.Errorf("DetectDialect() = %+v, want %+v", d, want)
+	}
+	go pw.Write([]byte("10:00:02,6\n"))
+	r, err := NewDialectReader(replay, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"10:00:01", "10:00:02"} {
+		if rec, err := r.Read(); err != nil || rec[0] != want {
+			t.Fatalf("Read() = %q, %v, want the record of %s", rec, err, want)
+		}
+	}
+}
+
+// TestWhitespaceRepeatedHeader reads vmstat output, which has a banner line,
+// no label column and repeats its header.
+func TestWhitespaceRepeatedHeader(t *testing.T) {
+	vmstat := `procs -----------memory---------- ---swap-- -----io---- -system-- ------cpu-----
+ r  b   swpd   free   buff  cache   si   so    bi    bo   in   cs us sy id wa st
+ 1  0      0 812344  10240 402112    0    0     3     5   40   80  1  1 98  0  0
+procs -----------memory---------- ---swap-- -----io---- -system-- ------cpu-----
+ r  b   swpd   free   buff  cache   si   so    bi    bo   in   cs us sy id wa st
+ 2  0      0 812100  10240 402120    0    0     0    12   51   92  3  1 96  0  0
+`
+	r, err := NewFormatReader("csv", strings.NewReader(vmstat), FormatOptions{Whitespace: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.(*CSVReader).Header(); len(got) != 18 || got[0] != LabelColumn || got[1] != "r" || got[17] != "st" {
+		t.Errorf("Header() = %q, want %s followed by the vmstat columns", got, LabelColumn)
+	}
+	for _, want := range []string{"1", "2"} {
+		rec, err := r.Read()
+		if err != nil || len(rec) != 18 || rec[0] != "" || rec[1] != want {
+			t.Fatalf("Read() = %q, %v, want an unlabeled record of r = %s", rec, err, want)
+		}
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("Read() at the end = %v, want EOF", err)
+	}
+}
+
+// TestWhitespaceTimedHeader reads sar output, which repeats its header with
+// the time of the next records.
+func TestWhitespaceTimedHeader(t *testing.T) {
+	sar := `Linux 6.1.0 (web1)  10/19/2026  _x86_64_  (4 CPU)
+
+12:00:01     CPU     %user     %system     %idle
+12:00:02     all      1.00        0.50     98.50
+
+12:00:02     CPU     %user     %system     %idle
+12:00:03     all      2.00        0.50     97.50
+`
+	r, err := NewFormatReader("csv", strings.NewReader(sar), FormatOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.Columns(), []string{"CPU", "%user", "%system", "%idle"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Columns() = %q, want %q", got, want)
+	}
+	for _, want := range []string{"12:00:02", "12:00:03"} {
+		if rec, err := r.Read(); err != nil || rec[0] != want {
+			t.Fatalf("Read() = %q, %v, want the record of %s", rec, err, want)
+		}
+	}
+}
+
+// TestTrimmedHeader reads a sample file with padded column names.
+func TestTrimmedHeader(t *testing.T) {
+	for _, opts := range []FormatOptions{{}, {Whitespace: true}} {
+		f, err := os.Open("tools/sampledata/5col-demo-xval-time")
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := NewFormatReader("csv", f, opts)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := r.(*CSVReader).Header(), []string{"x", "Sin(x)", "Cos(x)", "Random(", "Random(x)"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Header() with %+v = %q, want %q", opts, got, want)
+		}
+	}
+}
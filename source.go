@@ -0,0 +1,251 @@
+package datadash
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ColumnReader is a record reader which knows the names of its columns. The
+// first field of a record is the X-Axis label, the others belong to the
+// columns in order. Readers adding columns on the fly return longer records
+// as Columns grows.
+type ColumnReader interface {
+	Read() ([]string, error)
+	Columns() []string
+}
+
+// Source is an input of records. Open is called once before the records are
+// read and returns the header: the label column followed by the columns known
+// so far. Read returns io.EOF after the last record, other errors name the
+// source. Close releases the input.
+type Source interface {
+	ColumnReader
+	Open() ([]string, error)
+	Close() error
+}
+
+// SourceOptions configure the sources created by NewSource.
+type SourceOptions struct {
+	// Location is the input read by the source, e.g. the name of a file.
+	Location string
+	// NewReader returns the reader of the records of a stream. Default:
+	// NewCSVReader for tab separated data.
+	NewReader func(io.Reader) (ColumnReader, error)
+}
+
+// SourceFactory returns a source for the options.
+type SourceFactory func(opts SourceOptions) (Source, error)
+
+// FormatOptions configure the readers of the registered formats, each format
+// uses the fields it needs.
+type FormatOptions struct {
+	// Delimiter separates the fields of delimited data, detected from the
+	// first lines when 0.
+	Delimiter rune
+	// Whitespace splits delimited data on runs of spaces and tabs instead of
+	// Delimiter, for aligned columns.
+	Whitespace bool
+	// Header tells whether delimited data starts with a header line.
+	// Default: HeaderAuto, detected from the first lines.
+	Header HeaderMode
+	// LabelKey is the key of the X-Axis label of key=value formats.
+	LabelKey string
+	// Keys are the keys plotted by key=value formats, all numeric keys of
+	// the first record when empty.
+	Keys []string
+}
+
+// FormatFactory returns a reader of the records of r.
+type FormatFactory func(r io.Reader, opts FormatOptions) (ColumnReader, error)
+
+var registry = struct {
+	sync.Mutex
+	sources map[string]SourceFactory
+	formats map[string]FormatFactory
+}{
+	sources: map[string]SourceFactory{},
+	formats: map[string]FormatFactory{},
+}
+
+func init() {
+	RegisterSource("file", func(opts SourceOptions) (Source, error) {
+		if opts.Location == "" {
+			return nil, fmt.Errorf("file: no file name")
+		}
+		return NewStreamSource(opts.Location, func() (io.ReadCloser, error) {
+			return os.Open(opts.Location)
+		}, opts.NewReader), nil
+	})
+	RegisterSource("stdin", func(opts SourceOptions) (Source, error) {
+		return NewStreamSource("stdin", func() (io.ReadCloser, error) {
+			return io.NopCloser(os.Stdin), nil
+		}, opts.NewReader), nil
+	})
+
+	RegisterFormat("csv", func(r io.Reader, opts FormatOptions) (ColumnReader, error) {
+		d := Dialect{Delimiter: opts.Delimiter, Whitespace: opts.Whitespace, Header: opts.Header != HeaderAbsent}
+		if d.Whitespace {
+			d.Delimiter = ' '
+		}
+		if d.Delimiter == 0 || opts.Header == HeaderAuto {
+			detected, replay, err := DetectDialect(r, d)
+			if err != nil {
+				return nil, err
+			}
+			r = replay
+			d.Delimiter, d.Whitespace = detected.Delimiter, detected.Whitespace
+			if opts.Header == HeaderAuto {
+				d.Header = detected.Header
+			}
+		}
+		return NewDialectReader(r, d)
+	})
+	RegisterFormat("logfmt", func(r io.Reader, opts FormatOptions) (ColumnReader, error) {
+		lr := NewLogfmtReader(r, opts.LabelKey, opts.Keys)
+		_, err := lr.Header()
+		return lr, err
+	})
+	RegisterFormat("influx", func(r io.Reader, opts FormatOptions) (ColumnReader, error) {
+		ir := NewInfluxReader(r)
+		_, err := ir.Header()
+		return ir, err
+	})
+}
+
+// RegisterSource makes a source available by name, replacing a source of the
+// same name.
+func RegisterSource(name string, f SourceFactory) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.sources[name] = f
+}
+
+// NewSource returns a source of the named kind.
+func NewSource(name string, opts SourceOptions) (Source, error) {
+	registry.Lock()
+	f, ok := registry.sources[name]
+	registry.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown source %q, available sources: %s", name, strings.Join(SourceNames(), ", "))
+	}
+	return f(opts)
+}
+
+// SourceNames returns the names of the registered sources in sorted order.
+func SourceNames() []string {
+	registry.Lock()
+	defer registry.Unlock()
+	return sortedKeys(registry.sources)
+}
+
+// RegisterFormat makes a record format available by name, replacing a format
+// of the same name.
+func RegisterFormat(name string, f FormatFactory) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.formats[name] = f
+}
+
+// NewFormatReader returns a reader of the records of r in the named format.
+func NewFormatReader(name string, r io.Reader, opts FormatOptions) (ColumnReader, error) {
+	registry.Lock()
+	f, ok := registry.formats[name]
+	registry.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q, available formats: %s", name, strings.Join(FormatNames(), ", "))
+	}
+	return f(r, opts)
+}
+
+// FormatNames returns the names of the registered formats in sorted order.
+func FormatNames() []string {
+	registry.Lock()
+	defer registry.Unlock()
+	return sortedKeys(registry.formats)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StreamSource is a Source reading the records of a stream, such as a file or
+// Stdin, with a ColumnReader.
+type StreamSource struct {
+	Name      string
+	open      func() (io.ReadCloser, error)
+	newReader func(io.Reader) (ColumnReader, error)
+	stream    io.ReadCloser
+	reader    ColumnReader
+}
+
+// NewStreamSource returns a source reading the stream returned by open with
+// the reader returned by newReader, tab separated data when nil.
+func NewStreamSource(name string, open func() (io.ReadCloser, error), newReader func(io.Reader) (ColumnReader, error)) *StreamSource {
+	if newReader == nil {
+		newReader = func(r io.Reader) (ColumnReader, error) {
+			return NewCSVReader(r, '\t')
+		}
+	}
+	return &StreamSource{Name: name, open: open, newReader: newReader}
+}
+
+// Open opens the stream and reads its header. It returns io.EOF when the
+// stream is empty.
+func (s *StreamSource) Open() ([]string, error) {
+	stream, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	s.stream = stream
+	s.reader, err = s.newReader(bufio.NewReader(stream))
+	if err == io.EOF {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", s.Name, err)
+	}
+	if h, ok := s.reader.(interface{ Header() []string }); ok {
+		return h.Header(), nil
+	}
+	return append([]string{LabelColumn}, s.reader.Columns()...), nil
+}
+
+// Read returns the next record.
+func (s *StreamSource) Read() ([]string, error) {
+	record, err := s.reader.Read()
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("%s: %v", s.Name, err)
+	}
+	return record, err
+}
+
+// Columns returns the names of the columns seen so far.
+func (s *StreamSource) Columns() []string {
+	if s.reader == nil {
+		return nil
+	}
+	return s.reader.Columns()
+}
+
+// Close closes the stream.
+func (s *StreamSource) Close() error {
+	if s.stream == nil {
+		return nil
+	}
+	return s.stream.Close()
+}
+
+// Reader returns the reader of the records, nil until the source is open.
+func (s *StreamSource) Reader() ColumnReader {
+	return s.reader
+}
@@ -0,0 +1,81 @@
+package datadash
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFileSource(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "data.tsv")
+	if err := os.WriteFile(name, []byte("time\tlatency\terrors\n10:00:01\t5\t1\n10:00:02\t7\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	src, err := NewSource("file", SourceOptions{Location: name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	header, err := src.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"time", "latency", "errors"}; !reflect.DeepEqual(header, want) {
+		t.Errorf("Open() = %q, want %q", header, want)
+	}
+	var records [][]string
+	for {
+		record, err := src.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	want := [][]string{{"10:00:01", "5", "1"}, {"10:00:02", "7"}}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records = %q, want %q", records, want)
+	}
+}
+
+func TestFileSourceMissing(t *testing.T) {
+	src, err := NewSource("file", SourceOptions{Location: filepath.Join(t.TempDir(), "missing")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.Open(); err == nil {
+		t.Error("Open() of a missing file succeeded")
+	}
+}
+
+func TestRegisterSource(t *testing.T) {
+	RegisterSource("test", func(opts SourceOptions) (Source, error) {
+		return NewStreamSource("test", func() (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(opts.Location)), nil
+		}, opts.NewReader), nil
+	})
+	src, err := NewSource("test", SourceOptions{
+		Location: "x a=1\nx a=2\n",
+		NewReader: func(r io.Reader) (ColumnReader, error) {
+			return NewFormatReader("logfmt", r, FormatOptions{LabelKey: "x"})
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := src.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{LabelColumn, "a"}; !reflect.DeepEqual(header, want) {
+		t.Errorf("Open() = %q, want %q", header, want)
+	}
+	if _, err := NewSource("nope", SourceOptions{}); err == nil {
+		t.Error("NewSource of an unregistered source succeeded")
+	}
+}
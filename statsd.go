@@ -0,0 +1,283 @@
+package datadash
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StatsdMetric is one metric of a StatsD packet: name:value|type[|@rate].
+type StatsdMetric struct {
+	Name  string
+	Value float64
+	// Raw is the value as sent, the member of a set which needn't be a
+	// number.
+	Raw        string
+	Type       string
+	SampleRate float64
+	// Relative is set for gauges updated with a signed value (+3 or -3).
+	Relative bool
+}
+
+// ParseStatsdLine parses a single StatsD metric. Tags (|#tag:v) are ignored.
+func ParseStatsdLine(line string) (*StatsdMetric, error) {
+	parts := strings.Split(line, "|")
+	colon := strings.LastIndexByte(parts[0], ':')
+	if colon <= 0 {
+		return nil, fmt.Errorf("statsd: invalid metric %q", line)
+	}
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("statsd: missing type in %q", line)
+	}
+	value := parts[0][colon+1:]
+	m := &StatsdMetric{Name: parts[0][:colon], Raw: value, Type: parts[1], SampleRate: 1}
+	switch m.Type {
+	case "c", "g", "ms", "h", "s":
+	default:
+		return nil, fmt.Errorf("statsd: unknown type %q in %q", m.Type, line)
+	}
+	if m.Type == "s" {
+		if value == "" {
+			return nil, fmt.Errorf("statsd: missing value in %q", line)
+		}
+		return m, nil
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("statsd: invalid value %q in %q", value, line)
+	}
+	m.Value = v
+	m.Relative = m.Type == "g" && (strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-"))
+	for _, p := range parts[2:] {
+		if strings.HasPrefix(p, "@") {
+			rate, err := strconv.ParseFloat(p[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return nil, fmt.Errorf("statsd: invalid sample rate %q in %q", p, line)
+			}
+			m.SampleRate = rate
+		}
+	}
+	return m, nil
+}
+
+// StatsdServer receives StatsD metrics over UDP and aggregates them every
+// flush interval into one record, with a column per series: counters become
+// per second rates, timers become their mean, p90 and max, gauges keep their
+// last value and sets count their unique values. The sample rate of counters
+// and timers weighs their values. Like InfluxReader, columns are added as
+// metrics appear.
+type StatsdServer struct {
+	Interval time.Duration
+	conn     net.PacketConn
+	next     time.Time
+
+	mu       sync.Mutex
+	counters map[string]float64
+	timers   map[string][]timing
+	gauges   map[string]float64
+	sets     map[string]map[string]bool
+	columns  map[string]int
+	names    []string
+	packets  int
+	invalid  int
+	pending  []string
+}
+
+// NewStatsdServer listens for StatsD packets on the UDP address addr.
+func NewStatsdServer(addr string, interval time.Duration) (*StatsdServer, error) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &StatsdServer{
+		Interval: interval,
+		conn:     conn,
+		next:     time.Now().Add(interval),
+		counters: map[string]float64{},
+		timers:   map[string][]timing{},
+		gauges:   map[string]float64{},
+		sets:     map[string]map[string]bool{},
+		columns:  map[string]int{},
+	}
+	go s.receive()
+	return s, nil
+}
+
+// Addr returns the address the server listens on.
+func (s *StatsdServer) Addr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+// Close stops listening.
+func (s *StatsdServer) Close() error {
+	return s.conn.Close()
+}
+
+// Header waits for the first flush holding metrics and returns the label
+// column followed by its series. That flush is returned by the next Read.
+func (s *StatsdServer) Header() ([]string, error) {
+	for {
+		record, err := s.Read()
+		if err != nil {
+			return nil, err
+		}
+		if len(record) > 1 {
+			s.pending = record
+			return append([]string{LabelColumn}, s.Columns()...), nil
+		}
+	}
+}
+
+// Read waits for the end of the flush interval and returns the aggregated
+// metrics as a record.
+func (s *StatsdServer) Read() ([]string, error) {
+	if record := s.pending; record != nil {
+		s.pending = nil
+		return record, nil
+	}
+	time.Sleep(time.Until(s.next))
+	now := time.Now()
+	s.next = s.next.Add(s.Interval)
+	return s.flush(now), nil
+}
+
+// Columns returns the names of the series seen so far. It is safe to call
+// concurrently with Read.
+func (s *StatsdServer) Columns() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.names...)
+}
+
+// Status returns the number of packets received and of invalid metrics.
+func (s *StatsdServer) Status() (packets, invalid int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.packets, s.invalid
+}
+
+func (s *StatsdServer) receive() {
+	buf := make([]byte, 65535)
+	for {
+		n, _, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.packets++
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			if line = strings.TrimSpace(line); line == "" {
+				continue
+			}
+			m, err := ParseStatsdLine(line)
+			if err != nil {
+				s.invalid++
+				continue
+			}
+			s.add(m)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// add aggregates m into the current interval. Caller must hold s.mu.
+func (s *StatsdServer) add(m *StatsdMetric) {
+	switch m.Type {
+	case "c":
+		s.counters[m.Name] += m.Value / m.SampleRate
+	case "ms", "h":
+		s.timers[m.Name] = append(s.timers[m.Name], timing{m.Value, 1 / m.SampleRate})
+	case "g":
+		if m.Relative {
+			s.gauges[m.Name] += m.Value
+		} else {
+			s.gauges[m.Name] = m.Value
+		}
+	case "s":
+		if s.sets[m.Name] == nil {
+			s.sets[m.Name] = map[string]bool{}
+		}
+		s.sets[m.Name][m.Raw] = true
+	}
+}
+
+// flush returns the record of the ended interval and starts a new one.
+func (s *StatsdServer) flush(now time.Time) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values := map[string]float64{}
+	for name, count := range s.counters {
+		values[name] = count / s.Interval.Seconds()
+		s.counters[name] = 0
+	}
+	for name, t := range s.timers {
+		if len(t) == 0 {
+			continue
+		}
+		mean, p90, max := timingStats(t)
+		values[name+".mean"] = mean
+		values[name+".p90"] = p90
+		values[name+".max"] = max
+		s.timers[name] = t[:0]
+	}
+	for name, g := range s.gauges {
+		values[name] = g
+	}
+	for name, set := range s.sets {
+		values[name] = float64(len(set))
+		s.sets[name] = map[string]bool{}
+	}
+
+	//register new series in a stable order
+	names := make([]string, 0, len(values))
+	for name := range values {
+		if _, ok := s.columns[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s.columns[name] = len(s.names)
+		s.names = append(s.names, name)
+	}
+
+	record := make([]string, len(s.names)+1)
+	record[0] = now.Format("15:04:05")
+	for name, v := range values {
+		record[s.columns[name]+1] = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return record
+}
+
+// timing is a timer value, weighing as many values as it was sampled from.
+type timing struct {
+	value  float64
+	weight float64
+}
+
+// timingStats returns the weighted mean, the weighted p90 (nearest rank) and
+// the max of the timer values t, which it sorts.
+func timingStats(t []timing) (mean, p90, max float64) {
+	sort.Slice(t, func(i, j int) bool { return t[i].value < t[j].value })
+	var sum, total float64
+	for _, v := range t {
+		sum += v.value * v.weight
+		total += v.weight
+	}
+	mean = sum / total
+	max = t[len(t)-1].value
+	rank := total * 90 / 100
+	var seen float64
+	for _, v := range t {
+		seen += v.weight
+		if seen >= rank {
+			p90 = v.value
+			break
+		}
+	}
+	return mean, p90, max
+}
@@ -0,0 +1,118 @@
+package datadash
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseStatsdLine(t *testing.T) {
+	for _, tc := range []struct {
+		line string
+		want StatsdMetric
+	}{
+		{"hits:3|c", StatsdMetric{Name: "hits", Value: 3, Raw: "3", Type: "c", SampleRate: 1}},
+		{"hits:1|c|@0.1", StatsdMetric{Name: "hits", Value: 1, Raw: "1", Type: "c", SampleRate: 0.1}},
+		{"hits:1|c|#env:prod,@x|@0.5", StatsdMetric{Name: "hits", Value: 1, Raw: "1", Type: "c", SampleRate: 0.5}},
+		{"db.query:12.5|ms|@0.1", StatsdMetric{Name: "db.query", Value: 12.5, Raw: "12.5", Type: "ms", SampleRate: 0.1}},
+		{"size:512|h", StatsdMetric{Name: "size", Value: 512, Raw: "512", Type: "h", SampleRate: 1}},
+		{"temp:21|g", StatsdMetric{Name: "temp", Value: 21, Raw: "21", Type: "g", SampleRate: 1}},
+		{"temp:+2|g", StatsdMetric{Name: "temp", Value: 2, Raw: "+2", Type: "g", SampleRate: 1, Relative: true}},
+		{"temp:-1.5|g", StatsdMetric{Name: "temp", Value: -1.5, Raw: "-1.5", Type: "g", SampleRate: 1, Relative: true}},
+		{"user:alice|s", StatsdMetric{Name: "user", Raw: "alice", Type: "s", SampleRate: 1}},
+		{"a:b:42|c", StatsdMetric{Name: "a:b", Value: 42, Raw: "42", Type: "c", SampleRate: 1}},
+	} {
+		m, err := ParseStatsdLine(tc.line)
+		if err != nil {
+			t.Errorf("ParseStatsdLine(%q): %v", tc.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(*m, tc.want) {
+			t.Errorf("ParseStatsdLine(%q) = %+v, want %+v", tc.line, *m, tc.want)
+		}
+	}
+	for _, line := range []string{"hits", ":1|c", "hits:1", "hits:1|x", "hits:one|c", "hits:1|c|@0", "hits:1|c|@2", "hits:1|ms|@x", "user:|s"} {
+		if _, err := ParseStatsdLine(line); err == nil {
+			t.Errorf("ParseStatsdLine(%q) succeeded, want an error", line)
+		}
+	}
+}
+
+func TestStatsdAggregation(t *testing.T) {
+	s, err := NewStatsdServer("127.0.0.1:0", 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	add := func(lines ...string) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, line := range lines {
+			m, err := ParseStatsdLine(line)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.add(m)
+		}
+	}
+	add(
+		//10 hits, 4 of them sampled at 1/2
+		"hits:6|c", "hits:2|c|@0.5",
+		//a 90 weighing as 10 values
+		"lat:10|ms", "lat:20|ms", "lat:90|ms|@0.1", "lat:30|ms",
+		"temp:20|g", "temp:+5|g", "temp:-2|g",
+		"user:alice|s", "user:bob|s", "user:alice|s", "user:42|s",
+	)
+	now := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	record := s.flush(now)
+	columns := []string{"hits", "lat.max", "lat.mean", "lat.p90", "temp", "user"}
+	if !reflect.DeepEqual(s.Columns(), columns) {
+		t.Fatalf("Columns() = %q, want %q", s.Columns(), columns)
+	}
+	//hits per second, timers weighted by their sample rate
+	if want := []string{"10:00:00", "5", "90", "73.84615384615384", "90", "23", "3"}; !reflect.DeepEqual(record, want) {
+		t.Errorf("first flush = %q, want %q", record, want)
+	}
+
+	//counters and sets start over, gauges keep their value, timers without
+	//values are left empty
+	add("temp:+1|g", "user:carol|s")
+	record = s.flush(now.Add(2 * time.Second))
+	if want := []string{"10:00:02", "0", "", "", "", "24", "1"}; !reflect.DeepEqual(record, want) {
+		t.Errorf("second flush = %q, want %q", record, want)
+	}
+}
+
+func TestStatsdServer(t *testing.T) {
+	s, err := NewStatsdServer("127.0.0.1:0", 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	conn, err := net.Dial("udp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("hits:1|c\nbad\n\nqueue:7|g\n")); err != nil {
+		t.Fatal(err)
+	}
+	header, err := s.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"x", "hits", "queue"}; !reflect.DeepEqual(header, want) {
+		t.Errorf("Header() = %q, want %q", header, want)
+	}
+	record, err := s.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(record) != 3 || record[1] != "20" || record[2] != "7" {
+		t.Errorf("Read() = %q, want 20 hits per second and 7", record)
+	}
+	if packets, invalid := s.Status(); packets != 1 || invalid != 1 {
+		t.Errorf("Status() = %d, %d, want 1 packet and 1 invalid metric", packets, invalid)
+	}
+}
@@ -0,0 +1,153 @@
+package datadash
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mum4k/termdash/cell"
+)
+
+// Theme holds the colors used to draw the dashboard. Colors are Xterm
+// palette numbers (0-255), a value of -1 selects the terminal's default color.
+type Theme struct {
+	Name string
+	// Series colors are assigned to rows in order and cycle when there are
+	// more rows than colors.
+	Series []int
+	// Border is the color of the statistics and graph borders.
+	Border int
+	// Text is the color of the statistics body.
+	Text int
+	// Pointer is the color of the current X-Axis label in the statistics panel.
+	Pointer int
+	// Value is the color of the current value in the statistics panel.
+	Value int
+	// Axes, XLabels and YLabels color the line chart axes and their labels.
+	Axes    int
+	XLabels int
+	YLabels int
+	// Average is the color of the average line.
+	Average int
+	// Baseline is the dimmed color of the series of a previous run.
+	Baseline int
+	// BarValue is the color of the values printed on top of bars.
+	BarValue int
+}
+
+// DefaultTheme is the theme used when a row has none set.
+const DefaultTheme = "dark"
+
+var themes = map[string]*Theme{
+	"dark": {
+		Name:     "dark",
+		Series:   []int{82, 13, 45, 9, 165, 214, 39, 226},
+		Border:   25,
+		Text:     3,
+		Pointer:  248,
+		Value:    15,
+		Axes:     8,
+		XLabels:  248,
+		YLabels:  15,
+		Average:  239,
+		Baseline: 240,
+		BarValue: 0,
+	},
+	"light": {
+		Name:     "light",
+		Series:   []int{28, 90, 25, 124, 130, 30, 94, 55},
+		Border:   245,
+		Text:     236,
+		Pointer:  240,
+		Value:    232,
+		Axes:     244,
+		XLabels:  240,
+		YLabels:  236,
+		Average:  250,
+		Baseline: 252,
+		BarValue: 231,
+	},
+	"solarized": {
+		Name:     "solarized",
+		Series:   []int{33, 37, 64, 136, 166, 125, 61, 160},
+		Border:   240,
+		Text:     244,
+		Pointer:  245,
+		Value:    245,
+		Axes:     240,
+		XLabels:  244,
+		YLabels:  244,
+		Average:  235,
+		Baseline: 239,
+		BarValue: 234,
+	},
+	"high-contrast": {
+		Name:     "high-contrast",
+		Series:   []int{46, 201, 51, 226, 196, 231},
+		Border:   231,
+		Text:     231,
+		Pointer:  231,
+		Value:    231,
+		Axes:     231,
+		XLabels:  231,
+		YLabels:  231,
+		Average:  250,
+		Baseline: 244,
+		BarValue: 16,
+	},
+	"monochrome": {
+		Name:     "monochrome",
+		Series:   []int{7},
+		Border:   -1,
+		Text:     -1,
+		Pointer:  -1,
+		Value:    -1,
+		Axes:     -1,
+		XLabels:  -1,
+		YLabels:  -1,
+		Average:  8,
+		Baseline: 8,
+		BarValue: 0,
+	},
+}
+
+// LookupTheme returns the named theme.
+func LookupTheme(name string) (*Theme, error) {
+	t, ok := themes[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown theme %q, available themes: %s", name, strings.Join(ThemeNames(), ", "))
+	}
+	return t, nil
+}
+
+// ThemeNames returns the names of the available themes in sorted order.
+func ThemeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SeriesColor returns the color of the row with the given ID. Row IDs start
+// at 1 (0 is the streaming row) and cycle through the series colors, a theme
+// without any uses the default color.
+func (t *Theme) SeriesColor(id int) cell.Color {
+	if len(t.Series) == 0 {
+		return color(-1)
+	}
+	if id > 0 {
+		id--
+	}
+	if id < 0 {
+		id = 0
+	}
+	return color(t.Series[id%len(t.Series)])
+}
+
+// color converts a palette number into a cell color, -1 and other out of
+// range values select the default color.
+func color(n int) cell.Color {
+	return cell.ColorNumber(n)
+}
@@ -0,0 +1,78 @@
+package datadash
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mum4k/termdash/cell"
+)
+
+func TestLookupTheme(t *testing.T) {
+	for _, tc := range []struct {
+		name, want string
+	}{
+		{"dark", "dark"},
+		{"Light", "light"},
+		{"SOLARIZED", "solarized"},
+		{"high-contrast", "high-contrast"},
+		{"monochrome", "monochrome"},
+		{DefaultTheme, "dark"},
+	} {
+		theme, err := LookupTheme(tc.name)
+		if err != nil {
+			t.Errorf("LookupTheme(%q): %v", tc.name, err)
+			continue
+		}
+		if theme.Name != tc.want {
+			t.Errorf("LookupTheme(%q) = %q, want %q", tc.name, theme.Name, tc.want)
+		}
+	}
+	for _, name := range []string{"", "sunset", "dark "} {
+		theme, err := LookupTheme(name)
+		if err == nil {
+			t.Errorf("LookupTheme(%q) = %q, want an error", name, theme.Name)
+			continue
+		}
+		//the error lists the themes to choose from
+		if !strings.Contains(err.Error(), strings.Join(ThemeNames(), ", ")) {
+			t.Errorf("LookupTheme(%q) error = %q, want the available themes", name, err)
+		}
+	}
+}
+
+func TestThemeNames(t *testing.T) {
+	want := []string{"dark", "high-contrast", "light", "monochrome", "solarized"}
+	if got := ThemeNames(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ThemeNames() = %q, want %q", got, want)
+	}
+}
+
+func TestSeriesColor(t *testing.T) {
+	theme := &Theme{Series: []int{10, 20, 30}}
+	for _, tc := range []struct {
+		id   int
+		want int
+	}{
+		//the streaming row shares the color of the first row
+		{0, 10},
+		{1, 10},
+		{2, 20},
+		{3, 30},
+		//past the palette the colors start over
+		{4, 10},
+		{5, 20},
+		{7, 10},
+		{300, 30},
+	} {
+		if got := theme.SeriesColor(tc.id); got != cell.ColorNumber(tc.want) {
+			t.Errorf("SeriesColor(%d) = %v, want %v", tc.id, got, cell.ColorNumber(tc.want))
+		}
+	}
+	mono := &Theme{Series: []int{-1}}
+	for _, id := range []int{1, 2, 9} {
+		if got := mono.SeriesColor(id); got != cell.ColorDefault {
+			t.Errorf("SeriesColor(%d) of a default color = %v, want the default", id, got)
+		}
+	}
+}